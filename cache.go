@@ -0,0 +1,191 @@
+package gosql
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// DefaultStmtCache is the statement cache used by statements with Cache enabled unless configured otherwise
+var DefaultStmtCache = NewStmtCache(256)
+
+// StmtCache is a concurrency-safe cache of prepared statements keyed by database and query text
+// When the cache is full, the least recently used statement is evicted and closed
+type StmtCache struct {
+	mu        sync.Mutex
+	maxSize   int
+	entries   map[stmtCacheKey]*list.Element
+	lru       *list.List
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// StmtCacheStats represents the usage counters of a StmtCache
+type StmtCacheStats struct {
+	Hits      uint64 `json:"hits" yaml:"hits"`
+	Misses    uint64 `json:"misses" yaml:"misses"`
+	Evictions uint64 `json:"evictions" yaml:"evictions"`
+	Size      int    `json:"size" yaml:"size"`
+}
+
+type stmtCacheKey struct {
+	db    *sql.DB
+	query string
+}
+
+type stmtCacheEntry struct {
+	key  stmtCacheKey
+	stmt *sql.Stmt
+}
+
+// NewStmtCache creates a new statement cache holding up to maxSize statements, or unlimited number of statements if maxSize <= 0
+func NewStmtCache(maxSize int) *StmtCache {
+	return &StmtCache{
+		maxSize: maxSize,
+		entries: make(map[stmtCacheKey]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Stats returns the current usage counters of the cache
+func (c *StmtCache) Stats() StmtCacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return StmtCacheStats{Hits: c.hits, Misses: c.misses, Evictions: c.evictions, Size: c.lru.Len()}
+}
+
+// get returns the cached statement for the query without preparing it on a cache miss
+func (c *StmtCache) get(db *sql.DB, query string) (*sql.Stmt, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[stmtCacheKey{db: db, query: query}]
+	if !ok {
+		return nil, false
+	}
+	c.hits++
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*stmtCacheEntry).stmt, true
+}
+
+// prepareAfterCommit prepares and caches the statement once the transaction from the context has committed
+// and released its connection, does nothing if the transaction wasn't started by gosql
+func (c *StmtCache) prepareAfterCommit(ctx context.Context, db *sql.DB, query string) {
+	state, ok := txStateFromContext(ctx, db)
+	if !ok || state.hooks == nil {
+		return
+	}
+	state.hooks.mu.Lock()
+	defer state.hooks.mu.Unlock()
+	state.hooks.afterCommit = append(state.hooks.afterCommit, func(ctx context.Context) {
+		if db.Stats().Idle == 0 {
+			return
+		}
+		// errors are logged by prepare, the statement is prepared again by the next transaction
+		_, _ = c.prepare(ctx, db, query)
+	})
+}
+
+// prepare returns the cached statement for the query, preparing it on the database on a cache miss
+func (c *StmtCache) prepare(ctx context.Context, db *sql.DB, query string) (*sql.Stmt, error) {
+	key := stmtCacheKey{db: db, query: query}
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		c.hits++
+		c.lru.MoveToFront(elem)
+		c.mu.Unlock()
+		return elem.Value.(*stmtCacheEntry).stmt, nil
+	}
+	c.misses++
+	c.mu.Unlock()
+
+	// prepare without holding the lock, so that a slow prepare doesn't block other statements
+	slog.DebugContext(ctx, "Preparing cached statement", "query", query)
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to prepare cached statement", "query", query, "error", err)
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		// another goroutine has cached the same statement in the meantime
+		c.lru.MoveToFront(elem)
+		c.mu.Unlock()
+		closeStmt(ctx, stmt)
+		return elem.Value.(*stmtCacheEntry).stmt, nil
+	}
+	c.entries[key] = c.lru.PushFront(&stmtCacheEntry{key: key, stmt: stmt})
+	evicted := make([]*sql.Stmt, 0)
+	for c.maxSize > 0 && c.lru.Len() > c.maxSize {
+		entry := c.lru.Remove(c.lru.Back()).(*stmtCacheEntry)
+		delete(c.entries, entry.key)
+		c.evictions++
+		evicted = append(evicted, entry.stmt)
+	}
+	c.mu.Unlock()
+
+	for _, s := range evicted {
+		slog.DebugContext(ctx, "Evicted least recently used statement from cache")
+		closeStmt(ctx, s)
+	}
+	return stmt, nil
+}
+
+// RemoveQuery removes and closes the cached statements of the query for all databases
+func (c *StmtCache) RemoveQuery(ctx context.Context, query string) error {
+	return c.remove(ctx, func(key stmtCacheKey) bool { return key.query == query })
+}
+
+func (c *StmtCache) removeQuery(ctx context.Context, db *sql.DB, query string) error {
+	return c.remove(ctx, func(key stmtCacheKey) bool { return key.db == db && key.query == query })
+}
+
+func (c *StmtCache) removePrefix(ctx context.Context, db *sql.DB, prefix string) error {
+	return c.remove(ctx, func(key stmtCacheKey) bool { return key.db == db && strings.HasPrefix(key.query, prefix) })
+}
+
+// RemoveDB removes and closes all cached statements of the database
+func (c *StmtCache) RemoveDB(ctx context.Context, db *sql.DB) error {
+	return c.remove(ctx, func(key stmtCacheKey) bool { return key.db == db })
+}
+
+// Close removes and closes all cached statements
+func (c *StmtCache) Close(ctx context.Context) error {
+	return c.remove(ctx, func(key stmtCacheKey) bool { return true })
+}
+
+func (c *StmtCache) remove(ctx context.Context, matches func(stmtCacheKey) bool) error {
+	c.mu.Lock()
+	removed := make([]*sql.Stmt, 0)
+	for key, elem := range c.entries {
+		if matches(key) {
+			c.lru.Remove(elem)
+			delete(c.entries, key)
+			removed = append(removed, elem.Value.(*stmtCacheEntry).stmt)
+		}
+	}
+	c.mu.Unlock()
+
+	errs := make([]error, 0)
+	for _, stmt := range removed {
+		if err := stmt.Close(); err != nil {
+			slog.ErrorContext(ctx, "Failed to close cached statement", "error", err)
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return nil
+}
+
+func closeStmt(ctx context.Context, stmt *sql.Stmt) {
+	if err := stmt.Close(); err != nil {
+		slog.ErrorContext(ctx, "Failed to close statement", "error", err)
+	}
+}
@@ -0,0 +1,187 @@
+package gosql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+func TestStmtCacheEviction(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	cache := NewStmtCache(2)
+	queries := []string{
+		"SELECT COUNT(*) FROM departments",
+		"SELECT COUNT(*) FROM students",
+		"SELECT id FROM departments",
+	}
+
+	first, err := cache.prepare(ctx, db, queries[0])
+	if err != nil {
+		t.Fatalf("Failed to prepare statement: %v", err)
+	}
+	if _, err := cache.prepare(ctx, db, queries[1]); err != nil {
+		t.Fatalf("Failed to prepare statement: %v", err)
+	}
+	// Touch the first statement, so that the second one becomes the least recently used
+	if stmt, err := cache.prepare(ctx, db, queries[0]); err != nil || stmt != first {
+		t.Fatalf("Expected cached statement, got %v, err=%v", stmt, err)
+	}
+	if _, err := cache.prepare(ctx, db, queries[2]); err != nil {
+		t.Fatalf("Failed to prepare statement: %v", err)
+	}
+
+	stats := cache.Stats()
+	if stats != (StmtCacheStats{Hits: 1, Misses: 3, Evictions: 1, Size: 2}) {
+		t.Errorf("Unexpected cache stats: %+v", stats)
+	}
+	if _, ok := cache.entries[stmtCacheKey{db: db, query: queries[1]}]; ok {
+		t.Error("Expected least recently used statement to be evicted")
+	}
+
+	// Removed statements are closed
+	if err := cache.RemoveQuery(ctx, queries[0]); err != nil {
+		t.Fatalf("Failed to remove statement: %v", err)
+	}
+	var count int
+	if err := first.QueryRow().Scan(&count); err == nil {
+		t.Error("Expected removed statement to be closed")
+	}
+
+	if err := cache.RemoveDB(ctx, db); err != nil {
+		t.Fatalf("Failed to remove database statements: %v", err)
+	}
+	if stats := cache.Stats(); stats.Size != 0 {
+		t.Errorf("Expected empty cache, got %+v", stats)
+	}
+}
+
+func TestDaoCloseReleasesCachedStmts(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	countDBEntries := func(db *sql.DB) int {
+		DefaultStmtCache.mu.Lock()
+		defer DefaultStmtCache.mu.Unlock()
+		count := 0
+		for key := range DefaultStmtCache.entries {
+			if key.db == db {
+				count++
+			}
+		}
+		return count
+	}
+
+	otherDB := initDB(t)
+	defer otherDB.Close()
+	otherDao := newDepartmentDao(t, otherDB)
+	defer otherDao.Close(ctx)
+	otherDept := &Department{Name: "Other"}
+	if err := otherDao.Save(ctx, otherDept); err != nil {
+		t.Fatalf("Failed to save department: %v", err)
+	}
+	if _, err := otherDao.FindById(ctx, otherDept.ID); err != nil {
+		t.Fatalf("Failed to find department: %v", err)
+	}
+	otherCount := countDBEntries(otherDB)
+	if otherCount == 0 {
+		t.Fatal("Expected statements of the other DAO to be cached")
+	}
+
+	departmentDao := newDepartmentDao(t, db)
+	dept := &Department{Name: "Cached"}
+	if err := departmentDao.Save(ctx, dept); err != nil {
+		t.Fatalf("Failed to save department: %v", err)
+	}
+	if _, err := departmentDao.FindById(ctx, dept.ID); err != nil {
+		t.Fatalf("Failed to find department: %v", err)
+	}
+	if _, err := departmentDao.ListAfter(ctx, "", 10); err != nil {
+		t.Fatalf("Failed to list departments: %v", err)
+	}
+	if countDBEntries(db) == 0 {
+		t.Fatal("Expected DAO statements to be cached")
+	}
+
+	if err := departmentDao.Close(ctx); err != nil {
+		t.Fatalf("Failed to close DAO: %v", err)
+	}
+	if count := countDBEntries(db); count != 0 {
+		t.Errorf("Expected DAO statements to be released, got %d cached statements", count)
+	}
+	if count := countDBEntries(otherDB); count != otherCount {
+		t.Errorf("Expected statements of the other database to stay cached, got %d instead of %d", count, otherCount)
+	}
+}
+
+func TestCachedStmtSingleConnection(t *testing.T) {
+	// The transaction holds the only connection, so cached statements can't be prepared on the database within it
+	db := initDB(t)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	cache := NewStmtCache(0)
+	builder := newDepartmentDaoBuilder(db)
+	builder.StmtCache = cache
+	departmentDao, err := builder.Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create DAO: %v", err)
+	}
+	defer departmentDao.Close(ctx)
+
+	deadlineCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	dept := &Department{Name: "Single"}
+	if err := departmentDao.Save(deadlineCtx, dept); err != nil {
+		t.Fatalf("Failed to save department: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		found, err := departmentDao.FindById(deadlineCtx, dept.ID)
+		if err != nil {
+			t.Fatalf("Failed to find department: %v", err)
+		}
+		if found.Name != dept.Name {
+			t.Errorf("Expected department %q, got %q", dept.Name, found.Name)
+		}
+	}
+
+	// The statement is cached after the first transaction commits and reused by the second one
+	if stats := cache.Stats(); stats.Hits == 0 || stats.Size == 0 {
+		t.Errorf("Expected statement to be cached after commit and reused, got %+v", stats)
+	}
+}
+
+func TestStmtCloseKeepsOtherDatabases(t *testing.T) {
+	cache := NewStmtCache(0)
+	const query = "SELECT COUNT(*) FROM departments"
+	count := func(db *sql.DB, stmt *QueryValStmt[int]) {
+		_, err := QueryWithTx(ctx, db, RO, func(ctx context.Context, tx *sql.Tx) (int, error) {
+			return stmt.Query(ctx, tx)
+		})
+		if err != nil {
+			t.Fatalf("Failed to count departments: %v", err)
+		}
+	}
+
+	db := initDB(t)
+	defer db.Close()
+	stmt := &QueryValStmt[int]{BaseStmt: BaseStmt{Query: query, Cache: true, stmtCache: cache}}
+	count(db, stmt)
+
+	otherDB := initDB(t)
+	defer otherDB.Close()
+	otherStmt := &QueryValStmt[int]{BaseStmt: BaseStmt{Query: query, Cache: true, stmtCache: cache}}
+	count(otherDB, otherStmt)
+
+	if err := stmt.Close(ctx); err != nil {
+		t.Fatalf("Failed to close statement: %v", err)
+	}
+	if _, ok := cache.entries[stmtCacheKey{db: db, query: query}]; ok {
+		t.Error("Expected statement to be removed from the cache")
+	}
+	if _, ok := cache.entries[stmtCacheKey{db: otherDB, query: query}]; !ok {
+		t.Error("Expected statement of the other database to stay cached")
+	}
+}
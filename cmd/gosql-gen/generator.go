@@ -0,0 +1,539 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+const (
+	gosqlPath = "github.com/iglin/go-sql"
+	uuidPath  = "github.com/google/uuid"
+	// entityDirective marks the structs to generate DAOs for, e.g. //gosql:entity table=students
+	entityDirective = "gosql:entity"
+)
+
+// pkg holds the parsed structs of a package and the imports of their files
+type pkg struct {
+	name    string
+	structs map[string]*structDecl
+}
+
+type structDecl struct {
+	name    string
+	typ     *ast.StructType
+	doc     *ast.CommentGroup
+	imports map[string]string
+}
+
+// entity is the model of the code generated for an annotated struct
+type entity struct {
+	Name    string
+	Table   string
+	Columns []column
+	// Refs lists the referenced structs allocated by Receive
+	Refs    []ref
+	Finders []finder
+}
+
+type column struct {
+	Name  string
+	Const string
+	// Path is the path to the field from the entity, e.g. GenericEntity.ID or Department.ID
+	Path string
+	// Ref is the pointer to a referenced struct the field is accessed through, nil otherwise
+	Ref *ref
+	// Var is the variable holding the value of the field accessed through a reference in the arguments
+	Var string
+}
+
+type ref struct {
+	Field string
+	Type  string
+}
+
+type finder struct {
+	Method string
+	Param  string
+	Type   string
+	Const  string
+	List   bool
+}
+
+// field is a tagged field resolved within a struct
+type field struct {
+	column string
+	// name is the name of the field, followed by the name of the referenced field for references
+	name    string
+	path    []string
+	typ     ast.Expr
+	imports map[string]string
+	ref     *ref
+	find    bool
+	list    bool
+}
+
+// generate generates the DAO code of the annotated structs of the package in dir, skipping the output file
+func generate(dir, output string) ([]byte, error) {
+	p, err := parsePackage(dir, output)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0)
+	for name, decl := range p.structs {
+		if _, ok := directive(decl.doc); ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no structs annotated with //%s in %s", entityDirective, dir)
+	}
+	sort.Strings(names)
+
+	imports := map[string]string{"context": "", "database/sql": "", gosqlPath: "gosql"}
+	entities := make([]entity, 0, len(names))
+	for _, name := range names {
+		e, err := p.entity(p.structs[name], imports)
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, e)
+	}
+
+	var buf bytes.Buffer
+	if err := fileTemplate.Execute(&buf, map[string]any{
+		"Package":  p.name,
+		"Imports":  sortedImports(imports),
+		"Entities": entities,
+	}); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("format generated code: %w", err)
+	}
+	return src, nil
+}
+
+func parsePackage(dir, output string) (*pkg, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	fset := token.NewFileSet()
+	p := &pkg{structs: make(map[string]*structDecl)}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || name == output {
+			continue
+		}
+		file, err := parser.ParseFile(fset, filepath.Join(dir, name), nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		if p.name != "" && p.name != file.Name.Name {
+			return nil, fmt.Errorf("multiple packages in %s: %s and %s", dir, p.name, file.Name.Name)
+		}
+		p.name = file.Name.Name
+
+		imports := make(map[string]string)
+		for _, spec := range file.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			name := filepath.Base(path)
+			if spec.Name != nil {
+				name = spec.Name.Name
+			} else if path == gosqlPath {
+				name = "gosql"
+			}
+			imports[name] = path
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				typeSpec := spec.(*ast.TypeSpec)
+				st, ok := typeSpec.Type.(*ast.StructType)
+				if !ok {
+					continue
+				}
+				doc := typeSpec.Doc
+				if doc == nil && len(gen.Specs) == 1 {
+					doc = gen.Doc
+				}
+				p.structs[typeSpec.Name.Name] = &structDecl{name: typeSpec.Name.Name, typ: st, doc: doc, imports: imports}
+			}
+		}
+	}
+	if p.name == "" {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+	return p, nil
+}
+
+// directive returns the arguments of the entity directive within the comments and whether there is one
+func directive(doc *ast.CommentGroup) (string, bool) {
+	if doc == nil {
+		return "", false
+	}
+	for _, c := range doc.List {
+		if args, ok := strings.CutPrefix(c.Text, "//"+entityDirective); ok && (args == "" || args[0] == ' ') {
+			return strings.TrimSpace(args), true
+		}
+	}
+	return "", false
+}
+
+func (p *pkg) entity(decl *structDecl, imports map[string]string) (entity, error) {
+	e := entity{Name: decl.name, Table: snakeCase(decl.name) + "s"}
+	args, _ := directive(decl.doc)
+	for _, arg := range strings.Fields(args) {
+		key, value, _ := strings.Cut(arg, "=")
+		switch key {
+		case "table":
+			e.Table = value
+		default:
+			return e, fmt.Errorf("%s: unknown argument %q of //%s", decl.name, arg, entityDirective)
+		}
+	}
+
+	fields, err := p.fields(decl, nil, true)
+	if err != nil {
+		return e, fmt.Errorf("%s: %w", decl.name, err)
+	}
+	seen := make(map[string]bool)
+	for _, f := range fields {
+		if seen[f.column] {
+			return e, fmt.Errorf("%s: duplicate column %q", decl.name, f.column)
+		}
+		seen[f.column] = true
+
+		c := column{Name: f.column, Const: e.Name + "Column" + f.name, Path: strings.Join(f.path, "."), Ref: f.ref}
+		if f.ref != nil {
+			c.Var = lowerFirst(f.name)
+			if !slices.Contains(e.Refs, *f.ref) {
+				e.Refs = append(e.Refs, *f.ref)
+			}
+		}
+		e.Columns = append(e.Columns, c)
+
+		if f.find || f.list {
+			// only the types of finder parameters are referenced by the generated code
+			for name, path := range f.imports {
+				imports[path] = importName(name, path)
+			}
+			finder := finder{Param: lowerFirst(f.name), Type: types.ExprString(f.typ), Const: c.Const, List: f.list}
+			if f.list {
+				finder.Method = "ListBy" + f.name
+			} else {
+				finder.Method = "FindBy" + f.name
+			}
+			e.Finders = append(e.Finders, finder)
+		}
+	}
+	if !seen["id"] || !seen["version"] {
+		return e, fmt.Errorf("%s: id and version columns are missing, embed gosql.GenericEntity", decl.name)
+	}
+	return e, nil
+}
+
+// fields resolves the tagged fields of the struct, following embedded structs and references if withRefs is set
+func (p *pkg) fields(decl *structDecl, prefix []string, withRefs bool) ([]field, error) {
+	res := make([]field, 0)
+	for _, f := range decl.typ.Fields.List {
+		tag := ""
+		if f.Tag != nil {
+			unquoted, _ := strconv.Unquote(f.Tag.Value)
+			tag = reflect.StructTag(unquoted).Get("db")
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "-" {
+			continue
+		}
+
+		if len(f.Names) == 0 {
+			if name != "" {
+				return nil, fmt.Errorf("tagged embedded field %s is not supported", types.ExprString(f.Type))
+			}
+			embedded, err := p.embedded(decl, f.Type, prefix, withRefs)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, embedded...)
+			continue
+		}
+
+		find, list, refColumn, hasRef := false, false, "", false
+		for _, opt := range strings.Split(opts, ",") {
+			switch {
+			case opt == "find":
+				find = true
+			case opt == "list":
+				list = true
+			case strings.HasPrefix(opt, "ref="):
+				refColumn, hasRef = strings.TrimPrefix(opt, "ref="), true
+			}
+		}
+		if hasRef && !withRefs {
+			continue
+		}
+
+		for _, ident := range f.Names {
+			if !ident.IsExported() || name == "" {
+				continue
+			}
+			if find && list {
+				return nil, fmt.Errorf("field %s can't be tagged with both find and list", ident.Name)
+			}
+			path := append(slices.Clone(prefix), ident.Name)
+			resolved := field{
+				column:  name,
+				name:    ident.Name,
+				path:    path,
+				typ:     f.Type,
+				imports: usedImports(f.Type, decl.imports),
+				find:    find,
+				list:    list,
+			}
+			if hasRef {
+				refField, err := p.ref(ident.Name, f.Type, refColumn)
+				if err != nil {
+					return nil, err
+				}
+				resolved.name += refField.name
+				resolved.path = append(path, refField.path...)
+				resolved.typ = refField.typ
+				resolved.imports = refField.imports
+				if star, ok := f.Type.(*ast.StarExpr); ok {
+					resolved.ref = &ref{Field: strings.Join(path, "."), Type: types.ExprString(star.X)}
+				}
+			}
+			res = append(res, resolved)
+		}
+	}
+	return res, nil
+}
+
+// embedded resolves the fields of an embedded struct, which is either gosql.GenericEntity or a struct of the package
+func (p *pkg) embedded(decl *structDecl, typ ast.Expr, prefix []string, withRefs bool) ([]field, error) {
+	switch t := typ.(type) {
+	case *ast.SelectorExpr:
+		if x, ok := t.X.(*ast.Ident); ok && decl.imports[x.Name] == gosqlPath && t.Sel.Name == "GenericEntity" {
+			path := append(slices.Clone(prefix), "GenericEntity")
+			uuidType := &ast.SelectorExpr{X: ast.NewIdent("uuid"), Sel: ast.NewIdent("UUID")}
+			imports := map[string]string{"uuid": uuidPath}
+			return []field{
+				{column: "id", name: "ID", path: append(slices.Clone(path), "ID"), typ: uuidType, imports: imports},
+				{column: "version", name: "Version", path: append(slices.Clone(path), "Version"), typ: uuidType, imports: imports},
+			}, nil
+		}
+	case *ast.Ident:
+		if embedded, ok := p.structs[t.Name]; ok {
+			return p.fields(embedded, append(slices.Clone(prefix), t.Name), withRefs)
+		}
+	}
+	return nil, fmt.Errorf("embedded field %s is not supported, only gosql.GenericEntity and structs of the package are", types.ExprString(typ))
+}
+
+// ref resolves the field tagged with the column within the struct referenced by the field
+func (p *pkg) ref(name string, typ ast.Expr, column string) (field, error) {
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	ident, ok := typ.(*ast.Ident)
+	if !ok || p.structs[ident.Name] == nil {
+		return field{}, fmt.Errorf("field %s referencing %q is not a struct of the package", name, column)
+	}
+	fields, err := p.fields(p.structs[ident.Name], nil, false)
+	if err != nil {
+		return field{}, err
+	}
+	for _, f := range fields {
+		if f.column == column {
+			return f, nil
+		}
+	}
+	return field{}, fmt.Errorf("field %s references unknown column %q of %s", name, column, ident.Name)
+}
+
+// usedImports returns the imports of the file that the type expression refers to
+func usedImports(typ ast.Expr, imports map[string]string) map[string]string {
+	res := make(map[string]string)
+	ast.Inspect(typ, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && imports[x.Name] != "" {
+				res[x.Name] = imports[x.Name]
+			}
+		}
+		return true
+	})
+	return res
+}
+
+// importName returns the name of the import if it has to be named explicitly, empty otherwise
+func importName(name, path string) string {
+	if name == filepath.Base(path) {
+		return ""
+	}
+	return name
+}
+
+// sortedImports returns the standard library imports and the rest of the imports as pairs of names and paths sorted by path
+func sortedImports(imports map[string]string) [2][][2]string {
+	var res [2][][2]string
+	for path, name := range imports {
+		group := 0
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			group = 1
+		}
+		res[group] = append(res[group], [2]string{name, path})
+	}
+	for _, group := range res {
+		sort.Slice(group, func(i, j int) bool { return group[i][1] < group[j][1] })
+	}
+	return res
+}
+
+// lowerFirst lowercases the leading initialism or letter of the identifier, e.g. DepartmentID to departmentID and ID to id
+func lowerFirst(s string) string {
+	runes := []rune(s)
+	for i := range runes {
+		if !unicode.IsUpper(runes[i]) {
+			break
+		}
+		if i > 0 && i+1 < len(runes) && unicode.IsLower(runes[i+1]) {
+			break
+		}
+		runes[i] = unicode.ToLower(runes[i])
+	}
+	return string(runes)
+}
+
+// snakeCase converts the identifier to snake case, e.g. CourseGroup to course_group
+func snakeCase(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+var fileTemplate = template.Must(template.New("file").Parse(`// Code generated by gosql-gen. DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+{{- range index .Imports 0}}
+	{{index . 0}} "{{index . 1}}"
+{{- end}}
+{{range index .Imports 1}}
+	{{index . 0}} "{{index . 1}}"
+{{- end}}
+)
+{{range $e := .Entities}}
+// Table and columns of {{$e.Name}}
+const (
+	{{$e.Name}}Table = "{{$e.Table}}"
+{{- range $e.Columns}}
+	{{.Const}} = "{{.Name}}"
+{{- end}}
+)
+
+// New{{$e.Name}}DaoBuilder returns the builder of the {{$e.Name}} DAO with the functions generated from the db tags
+// The statements are generated from the table unless they are set, and the children functions do nothing
+func New{{$e.Name}}DaoBuilder(db *sql.DB) gosql.DaoBuilder[*{{$e.Name}}] {
+	return gosql.DaoBuilder[*{{$e.Name}}]{
+		DB: db,
+		Table: &gosql.Table{
+			Name: {{$e.Name}}Table,
+			Columns: []string{ {{- range $i, $c := $e.Columns}}{{if $i}}, {{end}}{{$c.Const}}{{end -}} },
+		},
+{{- if $e.Finders}}
+		FilterColumns: map[string]string{
+{{- range $e.Finders}}
+			{{.Const}}: {{.Const}},
+{{- end}}
+		},
+{{- end}}
+		NewReceiver: func() *{{$e.Name}} { return &{{$e.Name}}{} },
+		Receive: func(e *{{$e.Name}}) []any {
+{{- range $e.Refs}}
+			if e.{{.Field}} == nil {
+				e.{{.Field}} = &{{.Type}}{}
+			}
+{{- end}}
+			return []any{ {{- range $i, $c := $e.Columns}}{{if $i}}, {{end}}&e.{{$c.Path}}{{end -}} }
+		},
+		InsertArgs: func(e *{{$e.Name}}) []any {
+{{- template "refVars" $e}}
+			return []any{ {{- range $i, $c := $e.Columns}}{{if $i}}, {{end}}{{template "arg" $c}}{{end -}} }
+		},
+		UpdateArgs: func(e *{{$e.Name}}) []any {
+{{- template "refVars" $e}}
+			return []any{ {{- range $e.Columns}}{{if ne .Name "id"}}{{template "arg" .}}, {{end}}{{end}}{{range $e.Columns}}{{if eq .Name "id"}}{{template "arg" .}}{{end}}{{end -}} }
+		},
+		SaveChildren:   func(ctx context.Context, tx *sql.Tx, e *{{$e.Name}}) error { return nil },
+		LoadChildren:   func(ctx context.Context, tx *sql.Tx, e *{{$e.Name}}) error { return nil },
+		DeleteChildren: func(ctx context.Context, tx *sql.Tx, e *{{$e.Name}}) error { return nil },
+	}
+}
+
+// {{$e.Name}}Dao is the DAO of {{$e.Name}} with typed finders
+type {{$e.Name}}Dao struct {
+	gosql.Dao[*{{$e.Name}}]
+}
+
+// New{{$e.Name}}Dao builds the {{$e.Name}} DAO from the builder, see New{{$e.Name}}DaoBuilder
+func New{{$e.Name}}Dao(ctx context.Context, b gosql.DaoBuilder[*{{$e.Name}}]) (*{{$e.Name}}Dao, error) {
+	dao, err := b.Build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &{{$e.Name}}Dao{Dao: dao}, nil
+}
+{{range $e.Finders}}
+{{- if .List}}
+// {{.Method}} retrieves the entities with the {{.Param}}
+func (dao *{{$e.Name}}Dao) {{.Method}}(ctx context.Context, {{.Param}} {{.Type}}, sort ...gosql.Sort) ([]*{{$e.Name}}, error) {
+	return dao.ListBy(ctx, gosql.Eq({{.Const}}, {{.Param}}), sort...)
+}
+{{else}}
+// {{.Method}} retrieves the entity with the {{.Param}}
+func (dao *{{$e.Name}}Dao) {{.Method}}(ctx context.Context, {{.Param}} {{.Type}}) (*{{$e.Name}}, error) {
+	return dao.FindOneBy(ctx, gosql.Eq({{.Const}}, {{.Param}}))
+}
+{{end}}
+{{- end}}
+{{- end}}
+{{- define "refVars"}}
+{{- range .Columns}}{{if .Ref}}
+			var {{.Var}} any
+			if e.{{.Ref.Field}} != nil {
+				{{.Var}} = e.{{.Path}}
+			}
+{{- end}}{{end}}
+{{- end}}
+{{- define "arg"}}{{if .Ref}}{{.Var}}{{else}}e.{{.Path}}{{end}}{{end}}
+`))
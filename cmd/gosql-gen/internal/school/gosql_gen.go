@@ -0,0 +1,141 @@
+// Code generated by gosql-gen. DO NOT EDIT.
+
+package school
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	gosql "github.com/iglin/go-sql"
+)
+
+// Table and columns of Department
+const (
+	DepartmentTable         = "departments"
+	DepartmentColumnID      = "id"
+	DepartmentColumnVersion = "version"
+	DepartmentColumnName    = "name"
+)
+
+// NewDepartmentDaoBuilder returns the builder of the Department DAO with the functions generated from the db tags
+// The statements are generated from the table unless they are set, and the children functions do nothing
+func NewDepartmentDaoBuilder(db *sql.DB) gosql.DaoBuilder[*Department] {
+	return gosql.DaoBuilder[*Department]{
+		DB: db,
+		Table: &gosql.Table{
+			Name:    DepartmentTable,
+			Columns: []string{DepartmentColumnID, DepartmentColumnVersion, DepartmentColumnName},
+		},
+		FilterColumns: map[string]string{
+			DepartmentColumnName: DepartmentColumnName,
+		},
+		NewReceiver: func() *Department { return &Department{} },
+		Receive: func(e *Department) []any {
+			return []any{&e.GenericEntity.ID, &e.GenericEntity.Version, &e.Name}
+		},
+		InsertArgs: func(e *Department) []any {
+			return []any{e.GenericEntity.ID, e.GenericEntity.Version, e.Name}
+		},
+		UpdateArgs: func(e *Department) []any {
+			return []any{e.GenericEntity.Version, e.Name, e.GenericEntity.ID}
+		},
+		SaveChildren:   func(ctx context.Context, tx *sql.Tx, e *Department) error { return nil },
+		LoadChildren:   func(ctx context.Context, tx *sql.Tx, e *Department) error { return nil },
+		DeleteChildren: func(ctx context.Context, tx *sql.Tx, e *Department) error { return nil },
+	}
+}
+
+// DepartmentDao is the DAO of Department with typed finders
+type DepartmentDao struct {
+	gosql.Dao[*Department]
+}
+
+// NewDepartmentDao builds the Department DAO from the builder, see NewDepartmentDaoBuilder
+func NewDepartmentDao(ctx context.Context, b gosql.DaoBuilder[*Department]) (*DepartmentDao, error) {
+	dao, err := b.Build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &DepartmentDao{Dao: dao}, nil
+}
+
+// FindByName retrieves the entity with the name
+func (dao *DepartmentDao) FindByName(ctx context.Context, name string) (*Department, error) {
+	return dao.FindOneBy(ctx, gosql.Eq(DepartmentColumnName, name))
+}
+
+// Table and columns of Student
+const (
+	StudentTable              = "students"
+	StudentColumnID           = "id"
+	StudentColumnVersion      = "version"
+	StudentColumnCreatedAt    = "created_at"
+	StudentColumnName         = "name"
+	StudentColumnEmail        = "email"
+	StudentColumnDepartmentID = "department_id"
+)
+
+// NewStudentDaoBuilder returns the builder of the Student DAO with the functions generated from the db tags
+// The statements are generated from the table unless they are set, and the children functions do nothing
+func NewStudentDaoBuilder(db *sql.DB) gosql.DaoBuilder[*Student] {
+	return gosql.DaoBuilder[*Student]{
+		DB: db,
+		Table: &gosql.Table{
+			Name:    StudentTable,
+			Columns: []string{StudentColumnID, StudentColumnVersion, StudentColumnCreatedAt, StudentColumnName, StudentColumnEmail, StudentColumnDepartmentID},
+		},
+		FilterColumns: map[string]string{
+			StudentColumnEmail:        StudentColumnEmail,
+			StudentColumnDepartmentID: StudentColumnDepartmentID,
+		},
+		NewReceiver: func() *Student { return &Student{} },
+		Receive: func(e *Student) []any {
+			if e.Department == nil {
+				e.Department = &Department{}
+			}
+			return []any{&e.GenericEntity.ID, &e.GenericEntity.Version, &e.Audit.CreatedAt, &e.Name, &e.Email, &e.Department.GenericEntity.ID}
+		},
+		InsertArgs: func(e *Student) []any {
+			var departmentID any
+			if e.Department != nil {
+				departmentID = e.Department.GenericEntity.ID
+			}
+			return []any{e.GenericEntity.ID, e.GenericEntity.Version, e.Audit.CreatedAt, e.Name, e.Email, departmentID}
+		},
+		UpdateArgs: func(e *Student) []any {
+			var departmentID any
+			if e.Department != nil {
+				departmentID = e.Department.GenericEntity.ID
+			}
+			return []any{e.GenericEntity.Version, e.Audit.CreatedAt, e.Name, e.Email, departmentID, e.GenericEntity.ID}
+		},
+		SaveChildren:   func(ctx context.Context, tx *sql.Tx, e *Student) error { return nil },
+		LoadChildren:   func(ctx context.Context, tx *sql.Tx, e *Student) error { return nil },
+		DeleteChildren: func(ctx context.Context, tx *sql.Tx, e *Student) error { return nil },
+	}
+}
+
+// StudentDao is the DAO of Student with typed finders
+type StudentDao struct {
+	gosql.Dao[*Student]
+}
+
+// NewStudentDao builds the Student DAO from the builder, see NewStudentDaoBuilder
+func NewStudentDao(ctx context.Context, b gosql.DaoBuilder[*Student]) (*StudentDao, error) {
+	dao, err := b.Build(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &StudentDao{Dao: dao}, nil
+}
+
+// FindByEmail retrieves the entity with the email
+func (dao *StudentDao) FindByEmail(ctx context.Context, email string) (*Student, error) {
+	return dao.FindOneBy(ctx, gosql.Eq(StudentColumnEmail, email))
+}
+
+// ListByDepartmentID retrieves the entities with the departmentID
+func (dao *StudentDao) ListByDepartmentID(ctx context.Context, departmentID uuid.UUID, sort ...gosql.Sort) ([]*Student, error) {
+	return dao.ListBy(ctx, gosql.Eq(StudentColumnDepartmentID, departmentID), sort...)
+}
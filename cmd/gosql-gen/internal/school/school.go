@@ -0,0 +1,55 @@
+// Package school holds the example entities of gosql-gen, its generated code is the golden file of the generator's tests
+package school
+
+import (
+	"time"
+
+	gosql "github.com/iglin/go-sql"
+)
+
+//go:generate go run github.com/iglin/go-sql/cmd/gosql-gen
+
+// Department is an entity with a unique name
+//
+//gosql:entity
+type Department struct {
+	gosql.GenericEntity
+	Name string `db:"name,find"`
+}
+
+// Equals reports whether the department has the same ID, version and name as another one
+func (d *Department) Equals(another any) bool {
+	other, ok := another.(*Department)
+	return ok && other != nil && d.ID == other.ID && d.Version == other.Version && d.Name == other.Name
+}
+
+// Audit holds the audit columns shared by entities
+type Audit struct {
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// Student is an entity referencing its department
+//
+//gosql:entity table=students
+type Student struct {
+	gosql.GenericEntity
+	Audit
+	Name       string      `db:"name"`
+	Email      string      `db:"email,find"`
+	Department *Department `db:"department_id,ref=id,list"`
+	Grade      int         `db:"-"`
+	Nickname   string
+}
+
+// Equals reports whether the student has the same columns as another one
+func (s *Student) Equals(another any) bool {
+	other, ok := another.(*Student)
+	if !ok || other == nil || (s.Department == nil) != (other.Department == nil) {
+		return false
+	}
+	if s.Department != nil && s.Department.ID != other.Department.ID {
+		return false
+	}
+	return s.ID == other.ID && s.Version == other.Version && s.CreatedAt.Equal(other.CreatedAt) &&
+		s.Name == other.Name && s.Email == other.Email
+}
@@ -0,0 +1,77 @@
+package school
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func TestGeneratedDao(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to open sqlite3 database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`
+		CREATE TABLE departments (id TEXT PRIMARY KEY, version TEXT NOT NULL, name TEXT NOT NULL UNIQUE);
+		CREATE TABLE students (
+			id TEXT PRIMARY KEY,
+			version TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL,
+			name TEXT NOT NULL,
+			email TEXT NOT NULL UNIQUE,
+			department_id TEXT NOT NULL REFERENCES departments(id)
+		);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+
+	departmentDao, err := NewDepartmentDao(ctx, NewDepartmentDaoBuilder(db))
+	if err != nil {
+		t.Fatalf("Failed to create department DAO: %v", err)
+	}
+	defer departmentDao.Close(ctx)
+	studentDao, err := NewStudentDao(ctx, NewStudentDaoBuilder(db))
+	if err != nil {
+		t.Fatalf("Failed to create student DAO: %v", err)
+	}
+	defer studentDao.Close(ctx)
+
+	math, physics := &Department{Name: "Math"}, &Department{Name: "Physics"}
+	if err := departmentDao.Save(ctx, math, physics); err != nil {
+		t.Fatalf("Failed to save departments: %v", err)
+	}
+	createdAt := time.Date(2024, 9, 1, 8, 0, 0, 0, time.UTC)
+	john := &Student{Audit: Audit{CreatedAt: createdAt}, Name: "John", Email: "john@example.com", Department: math}
+	jane := &Student{Audit: Audit{CreatedAt: createdAt}, Name: "Jane", Email: "jane@example.com", Department: physics}
+	if err := studentDao.Save(ctx, john, jane); err != nil {
+		t.Fatalf("Failed to save students: %v", err)
+	}
+	jane.Department = math
+	if err := studentDao.Save(ctx, jane); err != nil {
+		t.Fatalf("Failed to update student: %v", err)
+	}
+
+	found, err := departmentDao.FindByName(ctx, "Physics")
+	if err != nil || found == nil || found.ID != physics.ID {
+		t.Errorf("Expected to find department %v, got %v, %v", physics.ID, found, err)
+	}
+	student, err := studentDao.FindByEmail(ctx, "john@example.com")
+	if err != nil || !john.Equals(student) {
+		t.Errorf("Expected to find student %+v, got %+v, %v", john, student, err)
+	}
+	students, err := studentDao.ListByDepartmentID(ctx, math.ID)
+	if err != nil || len(students) != 2 {
+		t.Errorf("Expected 2 students of the department, got %v, %v", students, err)
+	}
+	if _, err := studentDao.FindByEmail(ctx, "nobody@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows for missing student, got %v", err)
+	}
+}
@@ -0,0 +1,43 @@
+// Command gosql-gen generates type-safe DAOs for entity structs without reflection.
+//
+// Structs annotated with a //gosql:entity comment, optionally followed by table=name, are mapped by their db tags
+// the way gosql.Mapper maps them, e.g.
+//
+//	//gosql:entity table=students
+//	type Student struct {
+//		gosql.GenericEntity
+//		Email      string      `db:"email,find"`
+//		Department *Department `db:"department_id,ref=id,list"`
+//	}
+//
+// For every entity it generates the table and column constants, a NewStudentDaoBuilder function returning
+// a gosql.DaoBuilder with Receive, InsertArgs and UpdateArgs, and a StudentDao embedding gosql.Dao with
+// FindByEmail and ListByDepartmentID finders for the fields tagged with the find and list options.
+//
+// It is meant to be run by go generate in the package of the entities:
+//
+//	//go:generate go run github.com/iglin/go-sql/cmd/gosql-gen
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func main() {
+	dir := flag.String("dir", ".", "directory of the package with the entities")
+	output := flag.String("output", "gosql_gen.go", "name of the generated file within the directory")
+	flag.Parse()
+
+	src, err := generate(*dir, *output)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gosql-gen:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, *output), src, 0o644); err != nil {
+		fmt.Fprintln(os.Stderr, "gosql-gen:", err)
+		os.Exit(1)
+	}
+}
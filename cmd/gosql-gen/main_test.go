@@ -0,0 +1,105 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestGenerateGolden(t *testing.T) {
+	// The generated code of the example package is compiled and tested with it, so it serves as the golden file
+	dir := filepath.Join("internal", "school")
+	golden := filepath.Join(dir, "gosql_gen.go")
+
+	src, err := generate(dir, "gosql_gen.go")
+	if err != nil {
+		t.Fatalf("Failed to generate: %v", err)
+	}
+	if *update {
+		if err := os.WriteFile(golden, src, 0o644); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+	}
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Failed to read golden file: %v", err)
+	}
+	if string(src) != string(expected) {
+		t.Errorf("Generated code differs from %s, run go test with -update to update it:\n%s", golden, src)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		src         string
+		expectedErr string
+	}{
+		{
+			name:        "No entities",
+			src:         "type User struct{}",
+			expectedErr: "no structs annotated",
+		},
+		{
+			name:        "Missing GenericEntity",
+			src:         "//gosql:entity\ntype User struct {\n\tName string `db:\"name\"`\n}",
+			expectedErr: "id and version columns are missing",
+		},
+		{
+			name:        "Unknown directive argument",
+			src:         "//gosql:entity schema=public\ntype User struct {\n\tgosql.GenericEntity\n}",
+			expectedErr: `unknown argument "schema=public"`,
+		},
+		{
+			name:        "Duplicate column",
+			src:         "//gosql:entity\ntype User struct {\n\tgosql.GenericEntity\n\tKey string `db:\"id\"`\n}",
+			expectedErr: `duplicate column "id"`,
+		},
+		{
+			name:        "Reference of unknown column",
+			src:         "//gosql:entity\ntype User struct {\n\tgosql.GenericEntity\n\tGroup *Group `db:\"group_id,ref=code\"`\n}\ntype Group struct {\n\tgosql.GenericEntity\n}",
+			expectedErr: `references unknown column "code"`,
+		},
+		{
+			name:        "Find and list",
+			src:         "//gosql:entity\ntype User struct {\n\tgosql.GenericEntity\n\tName string `db:\"name,find,list\"`\n}",
+			expectedErr: "both find and list",
+		},
+		{
+			name:        "Embedded pointer",
+			src:         "//gosql:entity\ntype User struct {\n\t*gosql.GenericEntity\n}",
+			expectedErr: "embedded field *gosql.GenericEntity is not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			src := "package model\n\nimport gosql \"github.com/iglin/go-sql\"\n\nvar _ gosql.Entity\n\n" + tt.src + "\n"
+			if err := os.WriteFile(filepath.Join(dir, "model.go"), []byte(src), 0o644); err != nil {
+				t.Fatalf("Failed to write source: %v", err)
+			}
+			_, err := generate(dir, "gosql_gen.go")
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestNames(t *testing.T) {
+	for input, expected := range map[string]string{"DepartmentID": "departmentID", "ID": "id", "URLPath": "urlPath", "Email": "email"} {
+		if res := lowerFirst(input); res != expected {
+			t.Errorf("Expected lowerFirst(%q) to be %q, got %q", input, expected, res)
+		}
+	}
+	for input, expected := range map[string]string{"Student": "student", "CourseGroup": "course_group", "HTTPLog": "http_log"} {
+		if res := snakeCase(input); res != expected {
+			t.Errorf("Expected snakeCase(%q) to be %q, got %q", input, expected, res)
+		}
+	}
+}
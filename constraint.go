@@ -0,0 +1,80 @@
+package gosql
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrUniqueViolation is matched by the errors of statements violating a unique or primary key constraint
+	ErrUniqueViolation = errors.New("gosql: unique constraint violation")
+	// ErrForeignKeyViolation is matched by the errors of statements violating a foreign key constraint
+	ErrForeignKeyViolation = errors.New("gosql: foreign key constraint violation")
+	// ErrNotNullViolation is matched by the errors of statements violating a not null constraint
+	ErrNotNullViolation = errors.New("gosql: not null constraint violation")
+	// ErrCheckViolation is matched by the errors of statements violating a check constraint
+	ErrCheckViolation = errors.New("gosql: check constraint violation")
+)
+
+// ConstraintError is a constraint violation translated from a driver error
+// It matches its Kind and the driver error with errors.Is and errors.As
+type ConstraintError struct {
+	// Kind is one of ErrUniqueViolation, ErrForeignKeyViolation, ErrNotNullViolation and ErrCheckViolation
+	Kind error
+	// Constraint is the name of the violated constraint as reported by the driver, e.g. "users.email" for a unique or
+	// not null constraint on SQLite, empty if the driver doesn't report it
+	Constraint string
+	// Err is the original driver error
+	Err error
+}
+
+func (e *ConstraintError) Error() string {
+	if e.Constraint == "" {
+		return fmt.Sprintf("%v: %v", e.Kind, e.Err)
+	}
+	return fmt.Sprintf("%v on %s: %v", e.Kind, e.Constraint, e.Err)
+}
+
+func (e *ConstraintError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// ErrorTranslator translates the errors of a driver into constraint violations
+// It returns nil for errors it doesn't recognize, e.g. the errors of other drivers
+type ErrorTranslator func(err error) *ConstraintError
+
+var (
+	translatorsMu sync.RWMutex
+	// translators are consulted in the order of registration
+	translators []ErrorTranslator
+)
+
+// RegisterErrorTranslator registers a translator for the errors of a driver, which is consulted after the ones
+// registered before it. The sqlite subpackage registers the translator of SQLite errors when imported
+func RegisterErrorTranslator(translator ErrorTranslator) {
+	translatorsMu.Lock()
+	defer translatorsMu.Unlock()
+	translators = append(translators, translator)
+}
+
+// TranslateError returns the *ConstraintError of the first registered translator recognizing the error,
+// or the error as is if none of them does or it is already translated
+func TranslateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var constraintErr *ConstraintError
+	if errors.As(err, &constraintErr) {
+		return err
+	}
+
+	translatorsMu.RLock()
+	defer translatorsMu.RUnlock()
+	for _, translate := range translators {
+		if constraintErr := translate(err); constraintErr != nil {
+			return constraintErr
+		}
+	}
+	return err
+}
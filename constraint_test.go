@@ -0,0 +1,31 @@
+package gosql
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestTranslateError(t *testing.T) {
+	RegisterErrorTranslator(func(err error) *ConstraintError {
+		var stateErr sqlStateErr
+		if errors.As(err, &stateErr) && stateErr == "23505" {
+			return &ConstraintError{Kind: ErrUniqueViolation, Err: err}
+		}
+		return nil
+	})
+
+	if err := TranslateError(sqlStateErr("23505")); !errors.Is(err, ErrUniqueViolation) || !errors.Is(err, sqlStateErr("23505")) {
+		t.Errorf("Expected the registered translator to translate the error, got %v", err)
+	}
+	if err := TranslateError(sqlStateErr("23503")); !errors.Is(err, sqlStateErr("23503")) {
+		t.Errorf("Expected an unknown error to be returned as is, got %v", err)
+	}
+	translated := &ConstraintError{Kind: ErrCheckViolation, Err: sql.ErrNoRows}
+	if err := TranslateError(translated); err != translated {
+		t.Errorf("Expected a translated error to be returned as is, got %v", err)
+	}
+	if TranslateError(nil) != nil {
+		t.Errorf("Expected nil for nil error")
+	}
+}
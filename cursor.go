@@ -0,0 +1,263 @@
+package gosql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// ErrInvalidCursor is returned when a cursor cannot be decoded or doesn't match the statement's sort key
+var ErrInvalidCursor = errors.New("gosql: invalid cursor")
+
+// Cursor is an opaque position in a keyset-paginated result set, safe to hand to API clients
+// The empty cursor points to the beginning of the result set
+type Cursor string
+
+// cursorData is the decoded content of a Cursor
+type cursorData struct {
+	// Key holds the sort key values of the row the cursor points at
+	Key []any `json:"k"`
+	// Backward is set for cursors that point to the rows before the key rather than after it
+	Backward bool `json:"b,omitempty"`
+}
+
+// CursorPage represents a keyset-paginated result set of items
+type CursorPage[T any] struct {
+	Items []T    `json:"items" yaml:"items"`
+	Next  Cursor `json:"next,omitempty" yaml:"next,omitempty"`
+	Prev  Cursor `json:"prev,omitempty" yaml:"prev,omitempty"`
+}
+
+func encodeCursor(key []any, backward bool) (Cursor, error) {
+	data, err := json.Marshal(cursorData{Key: key, Backward: backward})
+	if err != nil {
+		return "", err
+	}
+	return Cursor(base64.RawURLEncoding.EncodeToString(data)), nil
+}
+
+// decode decodes the cursor, converting each key value to the type of the value at the same position of the template,
+// e.g. the key of an empty item, so that timestamps or byte slices are bound as such rather than as their JSON strings
+func (c Cursor) decode(template []any) (cursorData, error) {
+	var res cursorData
+	data, err := base64.RawURLEncoding.DecodeString(string(c))
+	if err != nil {
+		return res, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	var raw struct {
+		Key      []json.RawMessage `json:"k"`
+		Backward bool              `json:"b,omitempty"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return res, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+	}
+	if template != nil && len(raw.Key) != len(template) {
+		return res, fmt.Errorf("%w: cursor has %d key values instead of %d", ErrInvalidCursor, len(raw.Key), len(template))
+	}
+
+	res.Backward = raw.Backward
+	res.Key = make([]any, len(raw.Key))
+	for i, value := range raw.Key {
+		if template != nil && template[i] != nil {
+			typed := reflect.New(reflect.TypeOf(template[i]))
+			if err := json.Unmarshal(value, typed.Interface()); err != nil {
+				return res, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+			}
+			res.Key[i] = typed.Elem().Interface()
+			continue
+		}
+
+		decoder := json.NewDecoder(bytes.NewReader(value))
+		decoder.UseNumber()
+		if err := decoder.Decode(&res.Key[i]); err != nil {
+			return res, fmt.Errorf("%w: %w", ErrInvalidCursor, err)
+		}
+		// bind numbers as numbers rather than strings
+		if n, ok := res.Key[i].(json.Number); ok {
+			if i64, err := n.Int64(); err == nil {
+				res.Key[i] = i64
+			} else if f64, err := n.Float64(); err == nil {
+				res.Key[i] = f64
+			}
+		}
+	}
+	return res, nil
+}
+
+// DaoQueryCursorStmt represents a statement that returns a keyset-paginated result set
+type DaoQueryCursorStmt[T any] struct {
+	Query      string
+	Cache      bool
+	KeyColumns []string
+	Desc       bool
+	Key        func(T) []any
+}
+
+// ToStmt converts a DaoQueryCursorStmt to a QueryCursorStmt that can be used to execute an SQL query
+func (s *DaoQueryCursorStmt[T]) ToStmt(newReceiver func() T, receive func(T) []any) *QueryCursorStmt[T] {
+	return &QueryCursorStmt[T]{
+		BaseStmt:    BaseStmt{Query: s.Query, Cache: s.Cache},
+		KeyColumns:  s.KeyColumns,
+		Desc:        s.Desc,
+		Key:         s.Key,
+		NewReceiver: newReceiver,
+		Receive:     receive,
+	}
+}
+
+// QueryCursorStmt represents a statement that returns a keyset-paginated result set
+// Query is the base query without ORDER BY and LIMIT clauses. It is wrapped into a subquery that orders the rows
+// by KeyColumns and seeks past the cursor, so KeyColumns must be selected by the query and together identify a row uniquely
+type QueryCursorStmt[T any] struct {
+	BaseStmt
+	//KeyColumns: Columns of the sort key, e.g. name, id
+	KeyColumns []string
+	//Desc: Whether the rows are sorted in descending order of the sort key
+	Desc bool
+	//Key: Function that returns the sort key values of an item in the order of KeyColumns.
+	//It's also called on an empty item from NewReceiver to get the types the key values of cursors are decoded to
+	Key         func(T) []any
+	NewReceiver func() T
+	Receive     func(T) []any
+}
+
+// QueryAfter executes a gosql query with keyset pagination and returns up to limit items after the cursor,
+// or before it if the cursor was returned as CursorPage.Prev. The limit is validated as page size with DefaultPagingPolicy
+func (stmt *QueryCursorStmt[T]) QueryAfter(ctx context.Context, tx *sql.Tx, cursor Cursor, limit int, args ...any) (CursorPage[T], error) {
+	return stmt.queryAfter(ctx, tx, cursor, limit, DefaultPagingPolicy, args...)
+}
+
+func (stmt *QueryCursorStmt[T]) queryAfter(ctx context.Context, tx *sql.Tx, cursor Cursor, limit int, policy *PagingPolicy, args ...any) (CursorPage[T], error) {
+	slog.DebugContext(ctx, "Executing gosql query with keyset pagination", "stmt", stmt.Query, "args_count", len(args), "limit", limit)
+	paging := Paging{PageNum: 1, PageSize: limit}
+	if err := policy.Apply(&paging); err != nil {
+		slog.ErrorContext(ctx, "Invalid limit for keyset paginated query", "limit", limit, "error", err)
+		return CursorPage[T]{}, err
+	}
+	limit = paging.PageSize
+
+	var position cursorData
+	if cursor != "" {
+		var err error
+		if position, err = cursor.decode(stmt.Key(stmt.NewReceiver())); err != nil {
+			slog.ErrorContext(ctx, "Failed to decode cursor", "error", err)
+			return CursorPage[T]{}, err
+		}
+		if len(position.Key) != len(stmt.KeyColumns) {
+			slog.ErrorContext(ctx, "Cursor doesn't match sort key", "cursor_key", len(position.Key), "key_columns", len(stmt.KeyColumns))
+			return CursorPage[T]{}, ErrInvalidCursor
+		}
+	}
+
+	queryArgs := slices.Clone(args)
+	for i := range position.Key {
+		// each condition of the seek compares the key values up to its column
+		queryArgs = append(queryArgs, position.Key[:i+1]...)
+	}
+	queryArgs = append(queryArgs, stmt.getDialect().LimitOffsetArgs(limit+1, 0)...)
+	items, err := stmt.toQueryStmt(cursor != "", position.Backward).Query(ctx, tx, queryArgs...)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get items for keyset paginated query", "error", err)
+		return CursorPage[T]{}, err
+	}
+
+	hasMore := len(items) > limit
+	if hasMore {
+		items = items[:limit]
+	}
+	if position.Backward {
+		slices.Reverse(items)
+	}
+
+	result := CursorPage[T]{Items: items}
+	if len(items) == 0 {
+		return result, nil
+	}
+	// there are rows before the page if it was reached by seeking forward or if a backward seek found more rows
+	if (cursor != "" && !position.Backward) || (position.Backward && hasMore) {
+		if result.Prev, err = encodeCursor(stmt.Key(items[0]), true); err != nil {
+			slog.ErrorContext(ctx, "Failed to encode previous cursor", "error", err)
+			return CursorPage[T]{}, err
+		}
+	}
+	// there are rows after the page if it was reached by seeking backward or if a forward seek found more rows
+	if position.Backward || hasMore {
+		if result.Next, err = encodeCursor(stmt.Key(items[len(items)-1]), false); err != nil {
+			slog.ErrorContext(ctx, "Failed to encode next cursor", "error", err)
+			return CursorPage[T]{}, err
+		}
+	}
+	slog.DebugContext(ctx, "Keyset paginated query completed", "returned_items", len(items), "has_more", hasMore)
+	return result, nil
+}
+
+// toQueryStmt generates the statement that fetches a page starting from the beginning of the result set, or seeking past the cursor
+func (stmt *QueryCursorStmt[T]) toQueryStmt(seek, backward bool) *QueryStmt[T] {
+	// backward pages are fetched in reverse order and reversed back after scanning
+	desc := stmt.Desc != backward
+	direction, op := "ASC", ">"
+	if desc {
+		direction, op = "DESC", "<"
+	}
+
+	orderBy := make([]string, 0, len(stmt.KeyColumns))
+	for _, column := range stmt.KeyColumns {
+		orderBy = append(orderBy, column+" "+direction)
+	}
+
+	query := "SELECT * FROM (" + stmt.Query + ") AS gosql_cursor"
+	if seek {
+		// the expanded form of the row value comparison (a, b) > (?, ?), which isn't supported by every database
+		conditions := make([]string, 0, len(stmt.KeyColumns))
+		for i, column := range stmt.KeyColumns {
+			terms := make([]string, 0, i+1)
+			for _, previous := range stmt.KeyColumns[:i] {
+				terms = append(terms, previous+" = ?")
+			}
+			terms = append(terms, column+" "+op+" ?")
+			if i == 0 {
+				conditions = append(conditions, terms[0])
+			} else {
+				conditions = append(conditions, "("+strings.Join(terms, " AND ")+")")
+			}
+		}
+		query += " WHERE " + strings.Join(conditions, " OR ")
+	}
+	query += " ORDER BY " + strings.Join(orderBy, ", ") + " " + stmt.getDialect().LimitOffset()
+
+	return &QueryStmt[T]{
+		BaseStmt:    stmt.derive(query, stmt.Cache),
+		NewReceiver: stmt.NewReceiver,
+		Receive:     stmt.Receive,
+	}
+}
+
+// Close releases resources associated with the keyset paginated query statement
+func (stmt *QueryCursorStmt[T]) Close(ctx context.Context) error {
+	return stmt.close(ctx, nil)
+}
+
+func (stmt *QueryCursorStmt[T]) close(ctx context.Context, db *sql.DB) error {
+	slog.DebugContext(ctx, "Closing keyset paginated query statement")
+	errs := make([]error, 0)
+	for _, seek := range []bool{false, true} {
+		for _, backward := range []bool{false, true} {
+			if err := stmt.toQueryStmt(seek, backward).close(ctx, db); err != nil {
+				slog.ErrorContext(ctx, "Failed to close keyset paginated query statement", "error", err)
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	return nil
+}
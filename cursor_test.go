@@ -0,0 +1,211 @@
+package gosql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorEncoding(t *testing.T) {
+	id := uuid.New()
+	cursor, err := encodeCursor([]any{"name", 42, 1.5, id}, true)
+	if err != nil {
+		t.Fatalf("Failed to encode cursor: %v", err)
+	}
+
+	position, err := cursor.decode(nil)
+	if err != nil {
+		t.Fatalf("Failed to decode cursor: %v", err)
+	}
+	if !position.Backward {
+		t.Error("Expected backward cursor")
+	}
+	expected := []any{"name", int64(42), 1.5, id.String()}
+	if len(position.Key) != len(expected) {
+		t.Fatalf("Expected key %v, got %v", expected, position.Key)
+	}
+	for i := range expected {
+		if position.Key[i] != expected[i] {
+			t.Errorf("Expected key value %v (%T), got %v (%T)", expected[i], expected[i], position.Key[i], position.Key[i])
+		}
+	}
+
+	if _, err := Cursor("not a cursor!").decode(nil); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+
+	// Key values are decoded to the types of the template
+	created := time.Date(2026, 1, 2, 3, 4, 5, 6, time.FixedZone("CET", 3600))
+	cursor, err = encodeCursor([]any{created, []byte{0, 1, 2}, id, 42}, false)
+	if err != nil {
+		t.Fatalf("Failed to encode cursor: %v", err)
+	}
+	position, err = cursor.decode([]any{time.Time{}, []byte(nil), uuid.Nil, 0})
+	if err != nil {
+		t.Fatalf("Failed to decode cursor: %v", err)
+	}
+	if v, ok := position.Key[0].(time.Time); !ok || !v.Equal(created) {
+		t.Errorf("Expected timestamp %v, got %v (%T)", created, position.Key[0], position.Key[0])
+	}
+	if v, ok := position.Key[1].([]byte); !ok || !bytes.Equal(v, []byte{0, 1, 2}) {
+		t.Errorf("Expected bytes [0 1 2], got %v (%T)", position.Key[1], position.Key[1])
+	}
+	if position.Key[2] != id || position.Key[3] != 42 {
+		t.Errorf("Expected key values %v and 42, got %v (%T) and %v (%T)", id, position.Key[2], position.Key[2], position.Key[3], position.Key[3])
+	}
+	if _, err := cursor.decode([]any{time.Time{}}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor for a template of another length, got %v", err)
+	}
+	if _, err := cursor.decode([]any{0, []byte(nil), uuid.Nil, 0}); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor for a template of other types, got %v", err)
+	}
+}
+
+func TestQueryCursorStmtTimestampKey(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	_, err := db.Exec(`CREATE TABLE events (id INTEGER PRIMARY KEY, created_at TIMESTAMP NOT NULL)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+	// Ids don't follow the timestamps, so the pages are only in order if the timestamps are compared as such
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, offset := range []time.Duration{time.Hour, time.Millisecond, 25 * time.Hour, time.Minute, 3 * time.Second} {
+		if _, err := db.Exec(`INSERT INTO events (id, created_at) VALUES (?, ?)`, i+1, start.Add(offset)); err != nil {
+			t.Fatalf("Failed to insert event: %v", err)
+		}
+	}
+
+	type event struct {
+		ID        int
+		CreatedAt time.Time
+	}
+	stmt := &QueryCursorStmt[*event]{
+		BaseStmt:    BaseStmt{Query: `SELECT id, created_at FROM events`, Cache: true},
+		KeyColumns:  []string{"created_at", "id"},
+		Key:         func(e *event) []any { return []any{e.CreatedAt, e.ID} },
+		NewReceiver: func() *event { return &event{} },
+		Receive:     func(e *event) []any { return []any{&e.ID, &e.CreatedAt} },
+	}
+	defer stmt.Close(ctx)
+
+	ids := make([]int, 0)
+	cursor := Cursor("")
+	for {
+		page, err := QueryWithTx(ctx, db, RO, func(ctx context.Context, tx *sql.Tx) (CursorPage[*event], error) {
+			return stmt.QueryAfter(ctx, tx, cursor, 2)
+		})
+		if err != nil {
+			t.Fatalf("Failed to list events: %v", err)
+		}
+		for _, e := range page.Items {
+			ids = append(ids, e.ID)
+		}
+		if page.Next == "" {
+			break
+		}
+		cursor = page.Next
+	}
+	if !slices.Equal(ids, []int{2, 5, 4, 1, 3}) {
+		t.Errorf("Expected events in order of their timestamps [2 5 4 1 3], got %v", ids)
+	}
+}
+
+func TestDepartmentDaoListAfter(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	departmentDao := newDepartmentDao(t, db)
+	defer departmentDao.Close(ctx)
+
+	names := []string{"A", "B", "C", "D", "E"}
+	for _, name := range names {
+		if err := departmentDao.Save(ctx, &Department{Name: name}); err != nil {
+			t.Fatalf("Failed to save department: %v", err)
+		}
+	}
+
+	pageNames := func(page CursorPage[*Department]) string {
+		res := ""
+		for _, d := range page.Items {
+			res += d.Name
+		}
+		return res
+	}
+
+	// Walk forward
+	first, err := departmentDao.ListAfter(ctx, "", 2)
+	if err != nil {
+		t.Fatalf("Failed to list first page: %v", err)
+	}
+	if pageNames(first) != "AB" || first.Prev != "" || first.Next == "" {
+		t.Fatalf("Unexpected first page %s, prev=%q, next=%q", pageNames(first), first.Prev, first.Next)
+	}
+	second, err := departmentDao.ListAfter(ctx, first.Next, 2)
+	if err != nil {
+		t.Fatalf("Failed to list second page: %v", err)
+	}
+	if pageNames(second) != "CD" || second.Prev == "" || second.Next == "" {
+		t.Fatalf("Unexpected second page %s, prev=%q, next=%q", pageNames(second), second.Prev, second.Next)
+	}
+	last, err := departmentDao.ListAfter(ctx, second.Next, 2)
+	if err != nil {
+		t.Fatalf("Failed to list last page: %v", err)
+	}
+	if pageNames(last) != "E" || last.Prev == "" || last.Next != "" {
+		t.Fatalf("Unexpected last page %s, prev=%q, next=%q", pageNames(last), last.Prev, last.Next)
+	}
+
+	// Walk backward
+	prev, err := departmentDao.ListAfter(ctx, last.Prev, 2)
+	if err != nil {
+		t.Fatalf("Failed to list previous page: %v", err)
+	}
+	if pageNames(prev) != "CD" || prev.Prev == "" || prev.Next == "" {
+		t.Fatalf("Unexpected previous page %s, prev=%q, next=%q", pageNames(prev), prev.Prev, prev.Next)
+	}
+	prev, err = departmentDao.ListAfter(ctx, prev.Prev, 2)
+	if err != nil {
+		t.Fatalf("Failed to list previous page: %v", err)
+	}
+	if pageNames(prev) != "AB" || prev.Prev != "" || prev.Next == "" {
+		t.Fatalf("Unexpected previous page %s, prev=%q, next=%q", pageNames(prev), prev.Prev, prev.Next)
+	}
+
+	// Descending custom statement with a filter
+	desc := &QueryCursorStmt[*Department]{
+		BaseStmt:    BaseStmt{Query: `SELECT id, name, version FROM departments WHERE name <> ?`},
+		KeyColumns:  []string{"name", "id"},
+		Desc:        true,
+		Key:         func(d *Department) []any { return []any{d.Name, d.ID} },
+		NewReceiver: func() *Department { return &Department{} },
+		Receive:     func(d *Department) []any { return []any{&d.ID, &d.Name, &d.Version} },
+	}
+	page, err := departmentDao.ListAfterByStmt(ctx, desc, "", 3, "D")
+	if err != nil {
+		t.Fatalf("Failed to list descending page: %v", err)
+	}
+	if pageNames(page) != "ECB" {
+		t.Fatalf("Expected descending page ECB, got %s", pageNames(page))
+	}
+	page, err = departmentDao.ListAfterByStmt(ctx, desc, page.Next, 3, "D")
+	if err != nil {
+		t.Fatalf("Failed to list descending page: %v", err)
+	}
+	if pageNames(page) != "A" || page.Next != "" {
+		t.Fatalf("Expected last descending page A, got %s, next=%q", pageNames(page), page.Next)
+	}
+
+	// Cursors of another sort key are rejected
+	cursor, _ := encodeCursor([]any{"A"}, false)
+	if _, err := departmentDao.ListAfter(ctx, cursor, 2); !errors.Is(err, ErrInvalidCursor) {
+		t.Errorf("Expected ErrInvalidCursor, got %v", err)
+	}
+}
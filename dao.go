@@ -4,18 +4,40 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"iter"
 	"log/slog"
+	"reflect"
+	"strings"
 
 	"github.com/google/uuid"
 )
 
 var (
-	// ErrNotFound is returned when an entity cannot be found
-	ErrNotFound = errors.New("gosql: entity not found")
+	// ErrNotFound is returned when an entity cannot be found, it wraps sql.ErrNoRows so that errors.Is matches both
+	ErrNotFound = fmt.Errorf("gosql: entity not found: %w", sql.ErrNoRows)
 	// ErrVersionMismatch is returned when an entity's version doesn't match the expected version
 	ErrVersionMismatch = errors.New("gosql: version mismatch - entity was modified")
 )
 
+// MissingIdsError is returned by the deletes of a DAO reporting missing entities when some of the IDs don't exist,
+// the existing entities are deleted nonetheless. It matches ErrNotFound with errors.Is
+type MissingIdsError struct {
+	Ids []uuid.UUID
+}
+
+func (e *MissingIdsError) Error() string {
+	ids := make([]string, 0, len(e.Ids))
+	for _, id := range e.Ids {
+		ids = append(ids, id.String())
+	}
+	return fmt.Sprintf("gosql: entities not found: %s", strings.Join(ids, ", "))
+}
+
+func (e *MissingIdsError) Unwrap() error {
+	return ErrNotFound
+}
+
 // Entity defines the interface for database entities that can be managed by the DAO
 type Entity interface {
 	comparable
@@ -27,25 +49,37 @@ type Entity interface {
 }
 
 // Dao defines the interface for data access objects that manage entities
+// Every method runs within a transaction according to the propagation set in the context, see WithPropagation
+// Statements passed to the ByStmt methods are executed with the DAO's dialect and statement cache
 type Dao[T Entity] interface {
 	Save(ctx context.Context, entities ...T) error
 	FindById(ctx context.Context, id uuid.UUID) (T, error)
+	FindByIds(ctx context.Context, ids ...uuid.UUID) ([]T, error)
 	FindOneByStmt(ctx context.Context, stmt *QueryOneStmt[T], args ...any) (T, error)
+	FindOneBy(ctx context.Context, spec Spec) (T, error)
 	ListByStmt(ctx context.Context, stmt *QueryStmt[T], args ...any) ([]T, error)
-	ListAll(ctx context.Context) ([]T, error)
+	ListBy(ctx context.Context, spec Spec, sort ...Sort) ([]T, error)
+	ListAll(ctx context.Context, sort ...Sort) ([]T, error)
+	CountBy(ctx context.Context, spec Spec) (int, error)
+	StreamByStmt(ctx context.Context, stmt *QueryStmt[T], args ...any) iter.Seq2[T, error]
+	StreamAll(ctx context.Context, sort ...Sort) iter.Seq2[T, error]
 	ListPageByStmt(ctx context.Context, stmt *QueryPageStmt[T], paging Paging, args ...any) (Page[T], error)
-	ListPage(ctx context.Context, paging Paging) (Page[T], error)
+	ListPageBy(ctx context.Context, spec Spec, paging Paging, sort ...Sort) (Page[T], error)
+	ListPage(ctx context.Context, paging Paging, sort ...Sort) (Page[T], error)
+	ListAfterByStmt(ctx context.Context, stmt *QueryCursorStmt[T], cursor Cursor, limit int, args ...any) (CursorPage[T], error)
+	ListAfter(ctx context.Context, cursor Cursor, limit int) (CursorPage[T], error)
 	Delete(ctx context.Context, entities ...T) error
 	DeleteCascade(ctx context.Context, entities ...T) error
 	DeleteByIds(ctx context.Context, ids ...uuid.UUID) error
 	DeleteByIdsCascade(ctx context.Context, ids ...uuid.UUID) error
+	DeleteBy(ctx context.Context, spec Spec) error
 	Close(ctx context.Context) error
 }
 
 // GenericEntity is a base implementation of the Entity interface
 type GenericEntity struct {
-	ID      uuid.UUID `json:"id" yaml:"id"`
-	Version uuid.UUID `json:"version" yaml:"version"`
+	ID      uuid.UUID `json:"id" yaml:"id" db:"id"`
+	Version uuid.UUID `json:"version" yaml:"version" db:"version"`
 }
 
 // GetID returns the entity's ID
@@ -70,14 +104,31 @@ func (e *GenericEntity) SetVersion(version uuid.UUID) {
 
 // genericDao is a generic implementation of the Dao interface
 type genericDao[T Entity] struct {
-	db              *sql.DB
-	insertStmt      *ExecStmt
-	updateStmt      *ExecStmt
-	getByIdStmt     *QueryOneStmt[T]
-	listAllStmt     *QueryStmt[T]
-	listAllPageStmt *QueryPageStmt[T]
-	deleteByIdStmt  *ExecStmt
+	db                *sql.DB
+	insertStmt        *ExecStmt
+	updateStmt        *ExecStmt
+	getByIdStmt       *QueryOneStmt[T]
+	listAllStmt       *QueryStmt[T]
+	listAllPageStmt   *QueryPageStmt[T]
+	listAllCursorStmt *QueryCursorStmt[T]
+	deleteByIdStmt    *ExecStmt
+	findByIdsStmt     *QueryStmt[T]
+	deleteByIdsStmt   *ExecStmt
+	// updateByIdAndVersionStmt enables the compare-and-swap update of Save if set
+	updateByIdAndVersionStmt *ExecStmt
+	// deleteByIdAndVersionStmt enables the versioned delete mode of Delete and DeleteCascade if set
+	deleteByIdAndVersionStmt *ExecStmt
 
+	// stmtCache and dialect are applied to the statements passed to the ByStmt methods
+	stmtCache *StmtCache
+	dialect   Dialect
+
+	// entityType is the name of the entity type reported by errors
+	entityType     string
+	reportMissing  bool
+	pagingPolicy   *PagingPolicy
+	sortColumns    map[string]string
+	filterColumns  map[string]string
 	insertArgs     func(T) []any
 	updateArgs     func(T) []any
 	saveChildren   func(ctx context.Context, tx *sql.Tx, e T) error
@@ -85,7 +136,7 @@ type genericDao[T Entity] struct {
 	deleteChildren func(ctx context.Context, tx *sql.Tx, e T) error
 }
 
-// DaoBuilder builds new Dao[T] object with the provided parameters. All of the parameters are mandatory.
+// DaoBuilder builds new Dao[T] object with the provided parameters. All of the parameters are mandatory unless stated otherwise.
 type DaoBuilder[T Entity] struct {
 	//DB: SQL database connection to use for all operations
 	DB *sql.DB
@@ -93,14 +144,35 @@ type DaoBuilder[T Entity] struct {
 	InsertStmt *DaoExecStmt
 	//UpdateStmt: Statement for updating existing entities
 	UpdateStmt *DaoExecStmt
+	//UpdateByIdAndVersionStmt: Optional statement for updating existing entities that compares the version,
+	//e.g. WHERE id = ? AND version = ?, with the previous version bound after UpdateArgs, or to the previous_version parameter
+	//of statements with named parameters. If nil, it's derived from UpdateStmt by adding AND version = ? to its WHERE clause,
+	//which has to be the last clause. Save returns ErrVersionMismatch if no row is updated
+	UpdateByIdAndVersionStmt *DaoExecStmt
 	//GetByIdStmt: Statement for retrieving a single entity by ID
 	GetByIdStmt *DaoQueryOneStmt[T]
 	//ListAllStmt: Statement for retrieving all entities
 	ListAllStmt *DaoQueryStmt[T]
 	//ListAllPageStmt: Statement for retrieving paginated results of all entities
 	ListAllPageStmt *DaoQueryPageStmt[T]
+	//ListAllCursorStmt: Optional statement for retrieving keyset-paginated results of all entities, required by ListAfter
+	ListAllCursorStmt *DaoQueryCursorStmt[T]
 	//DeleteByIdStmt: Statement for deleting entity by its ID
 	DeleteByIdStmt *DaoExecStmt
+	//FindByIdsStmt: Optional statement for retrieving entities by a slice of IDs in a single query, e.g. WHERE id IN (?).
+	//FindByIds falls back to GetByIdStmt for every ID if it is nil
+	FindByIdsStmt *DaoQueryStmt[T]
+	//DeleteByIdsStmt: Optional statement for deleting entities by a slice of IDs in a single statement, e.g. WHERE id IN (?).
+	//DeleteByIds falls back to DeleteByIdStmt for every ID if it is nil
+	DeleteByIdsStmt *DaoExecStmt
+	//DeleteByIdAndVersionStmt: Optional statement for deleting entity by its ID and version, e.g. WHERE id = ? AND version = ?,
+	//or WHERE id = :id AND version = :version with named parameters.
+	//If set, Delete and DeleteCascade fail with ErrVersionMismatch, or ErrNotFound unless ReportMissing is set, when no row is deleted,
+	//while DeleteByIds and DeleteByIdsCascade still delete by ID only
+	DeleteByIdAndVersionStmt *DaoExecStmt
+	//ReportMissing: Optional flag making Delete, DeleteCascade, DeleteByIds and DeleteByIdsCascade return *MissingIdsError
+	//listing the IDs that don't exist, after deleting the existing entities
+	ReportMissing bool
 	//NewReceiver: Function that returns a new instance of the entity
 	NewReceiver func() T
 	//Receive: Function that returns the arguments for the update statement for a given entity
@@ -115,14 +187,37 @@ type DaoBuilder[T Entity] struct {
 	LoadChildren func(ctx context.Context, tx *sql.Tx, e T) error
 	//DeleteChildren: Function that deletes child entities associated with the parent entity
 	DeleteChildren func(ctx context.Context, tx *sql.Tx, e T) error
+	//StmtCache: Optional cache for the statements with Cache enabled, DefaultStmtCache is used if nil
+	StmtCache *StmtCache
+	//Dialect: Optional SQL dialect of the database, DefaultDialect is used if nil
+	Dialect Dialect
+	//PagingPolicy: Optional policy for validating paging of paginated listings, DefaultPagingPolicy is used if nil
+	PagingPolicy *PagingPolicy
+	//SortColumns: Optional mapping of API field names to SQL columns that listings can be sorted by.
+	//The columns must be selected by ListAllStmt, which is wrapped into a subquery ordered by them
+	SortColumns map[string]string
+	//FilterColumns: Optional mapping of API field names to SQL columns that specifications can filter by.
+	//The columns must be selected by ListAllStmt, which is wrapped into a subquery filtered by them
+	FilterColumns map[string]string
+	//Table: Optional description of the entity's table, from which the statements that are nil are generated for the dialect.
+	//NewReceiver, Receive, InsertArgs and UpdateArgs are derived from the entity's db tags by Mapper if nil
+	Table *Table
 }
 
 func (b DaoBuilder[T]) Build(ctx context.Context) (Dao[T], error) {
+	entityType := reflect.TypeFor[T]().String()
+	b, err := b.withTable(ctx)
+	if err != nil {
+		return nil, wrapError(err, "DaoBuilder.Build", "", entityType, uuid.Nil)
+	}
 	if err := b.validate(ctx); err != nil {
-		return nil, err
+		return nil, wrapError(err, "DaoBuilder.Build", "", entityType, uuid.Nil)
 	}
-	return &genericDao[T]{
+	dao := &genericDao[T]{
 		db:              b.DB,
+		stmtCache:       b.StmtCache,
+		dialect:         b.Dialect,
+		entityType:      entityType,
 		insertStmt:      b.InsertStmt.ToStmt(),
 		updateStmt:      b.UpdateStmt.ToStmt(),
 		getByIdStmt:     b.GetByIdStmt.ToStmt(b.NewReceiver, b.Receive),
@@ -134,7 +229,35 @@ func (b DaoBuilder[T]) Build(ctx context.Context) (Dao[T], error) {
 		saveChildren:    b.SaveChildren,
 		loadChildren:    b.LoadChildren,
 		deleteChildren:  b.DeleteChildren,
-	}, nil
+		reportMissing:   b.ReportMissing,
+		pagingPolicy:    b.PagingPolicy,
+		sortColumns:     b.SortColumns,
+		filterColumns:   b.FilterColumns,
+	}
+	if b.ListAllCursorStmt != nil {
+		dao.listAllCursorStmt = b.ListAllCursorStmt.ToStmt(b.NewReceiver, b.Receive)
+	}
+	if b.FindByIdsStmt != nil {
+		dao.findByIdsStmt = b.FindByIdsStmt.ToStmt(b.NewReceiver, b.Receive)
+	}
+	if b.DeleteByIdsStmt != nil {
+		dao.deleteByIdsStmt = b.DeleteByIdsStmt.ToStmt()
+	}
+	if b.UpdateByIdAndVersionStmt != nil {
+		dao.updateByIdAndVersionStmt = b.UpdateByIdAndVersionStmt.ToStmt()
+	} else {
+		// validated to be derivable
+		query, _ := versionedQuery(b.UpdateStmt.Query)
+		dao.updateByIdAndVersionStmt = &ExecStmt{BaseStmt: BaseStmt{Query: query, Cache: b.UpdateStmt.Cache}}
+	}
+	if b.DeleteByIdAndVersionStmt != nil {
+		dao.deleteByIdAndVersionStmt = b.DeleteByIdAndVersionStmt.ToStmt()
+	}
+	for _, stmt := range dao.baseStmts() {
+		stmt.stmtCache = b.StmtCache
+		stmt.dialect = b.Dialect
+	}
+	return dao, nil
 }
 
 func (b DaoBuilder[T]) validate(ctx context.Context) error {
@@ -158,6 +281,14 @@ func (b DaoBuilder[T]) validate(ctx context.Context) error {
 		slog.ErrorContext(ctx, "updateStmt.Query is empty")
 		return errors.New("gosql: updateStmt.Query is empty")
 	}
+	if b.UpdateByIdAndVersionStmt != nil && b.UpdateByIdAndVersionStmt.Query == "" {
+		slog.ErrorContext(ctx, "updateByIdAndVersionStmt query is empty")
+		return errors.New("gosql: updateByIdAndVersionStmt query is empty")
+	}
+	if _, ok := versionedQuery(b.UpdateStmt.Query); !ok && b.UpdateByIdAndVersionStmt == nil {
+		slog.ErrorContext(ctx, "updateByIdAndVersionStmt is nil and can't be derived from updateStmt", "query", b.UpdateStmt.Query)
+		return errors.New("gosql: updateByIdAndVersionStmt is nil and can't be derived from updateStmt")
+	}
 	if b.GetByIdStmt == nil {
 		slog.ErrorContext(ctx, "getByIdStmt is nil")
 		return errors.New("gosql: getByIdStmt is nil")
@@ -182,6 +313,26 @@ func (b DaoBuilder[T]) validate(ctx context.Context) error {
 		slog.ErrorContext(ctx, "listAllPageStmt.QueryStmt is nil")
 		return errors.New("gosql: listAllPageStmt.QueryStmt is nil")
 	}
+	if b.ListAllCursorStmt != nil && len(b.ListAllCursorStmt.KeyColumns) == 0 {
+		slog.ErrorContext(ctx, "listAllCursorStmt.KeyColumns is empty")
+		return errors.New("gosql: listAllCursorStmt.KeyColumns is empty")
+	}
+	if b.ListAllCursorStmt != nil && b.ListAllCursorStmt.Key == nil {
+		slog.ErrorContext(ctx, "listAllCursorStmt.Key is nil")
+		return errors.New("gosql: listAllCursorStmt.Key is nil")
+	}
+	for field, column := range b.SortColumns {
+		if field == "" || column == "" {
+			slog.ErrorContext(ctx, "sortColumns contains empty field or column", "field", field, "column", column)
+			return errors.New("gosql: sortColumns contains empty field or column")
+		}
+	}
+	for field, column := range b.FilterColumns {
+		if field == "" || column == "" {
+			slog.ErrorContext(ctx, "filterColumns contains empty field or column", "field", field, "column", column)
+			return errors.New("gosql: filterColumns contains empty field or column")
+		}
+	}
 	if b.DeleteByIdStmt == nil {
 		slog.ErrorContext(ctx, "deleteByIdStmt is nil")
 		return errors.New("gosql: deleteByIdStmt is nil")
@@ -190,6 +341,18 @@ func (b DaoBuilder[T]) validate(ctx context.Context) error {
 		slog.ErrorContext(ctx, "deleteByIdStmt.Query is empty")
 		return errors.New("gosql: deleteByIdStmt.Query is empty")
 	}
+	if b.FindByIdsStmt != nil && b.FindByIdsStmt.Query == "" {
+		slog.ErrorContext(ctx, "findByIdsStmt query is empty")
+		return errors.New("gosql: findByIdsStmt query is empty")
+	}
+	if b.DeleteByIdsStmt != nil && b.DeleteByIdsStmt.Query == "" {
+		slog.ErrorContext(ctx, "deleteByIdsStmt query is empty")
+		return errors.New("gosql: deleteByIdsStmt query is empty")
+	}
+	if b.DeleteByIdAndVersionStmt != nil && b.DeleteByIdAndVersionStmt.Query == "" {
+		slog.ErrorContext(ctx, "deleteByIdAndVersionStmt query is empty")
+		return errors.New("gosql: deleteByIdAndVersionStmt query is empty")
+	}
 	if b.NewReceiver == nil {
 		slog.ErrorContext(ctx, "newReceiver is nil")
 		return errors.New("gosql: newReceiver is nil")
@@ -222,7 +385,8 @@ func (b DaoBuilder[T]) validate(ctx context.Context) error {
 }
 
 // Save persists an entity to the database
-func (dao *genericDao[T]) Save(ctx context.Context, e ...T) error {
+func (dao *genericDao[T]) Save(ctx context.Context, e ...T) (err error) {
+	defer dao.wrapError(&err, "Save")
 	slog.DebugContext(ctx, "Saving entities", "entities_count", len(e))
 	if len(e) == 0 {
 		return nil
@@ -230,7 +394,7 @@ func (dao *genericDao[T]) Save(ctx context.Context, e ...T) error {
 	return ExecWithTx(ctx, dao.db, RW, func(ctx context.Context, tx *sql.Tx) error {
 		for _, entity := range e {
 			if err := dao.save(ctx, tx, entity); err != nil {
-				return err
+				return dao.error("", err, entity.GetID())
 			}
 		}
 		return nil
@@ -244,7 +408,7 @@ func (dao *genericDao[T]) save(ctx context.Context, tx *sql.Tx, e T) error {
 		e.SetVersion(uuid.New())
 		slog.DebugContext(ctx, "Inserting new entity", "id", e.GetID())
 
-		if err := dao.insertStmt.Exec(ctx, tx, dao.insertArgs(e)...); err != nil {
+		if _, err := dao.insertStmt.Exec(ctx, tx, dao.insertArgs(e)...); err != nil {
 			slog.ErrorContext(ctx, "Failed to insert entity", "id", e.GetID(), "error", err)
 			return err
 		}
@@ -265,14 +429,12 @@ func (dao *genericDao[T]) save(ctx context.Context, tx *sql.Tx, e T) error {
 			return nil
 		}
 
-		if e.GetVersion() != existing.GetVersion() {
-			slog.ErrorContext(ctx, "Version mismatch during update", "id", e.GetID(), "expected", existing.GetVersion(), "actual", e.GetVersion())
-			return ErrVersionMismatch
-		}
+		// the version is compared by the update itself, so that a concurrent update after the read is detected too
+		version := e.GetVersion()
 		e.SetVersion(uuid.New())
 
-		if err := dao.updateStmt.Exec(ctx, tx, dao.updateArgs(e)...); err != nil {
-			slog.ErrorContext(ctx, "Failed to update entity", "id", e.GetID(), "error", err)
+		if err := dao.update(ctx, tx, e, version); err != nil {
+			e.SetVersion(version)
 			return err
 		}
 	}
@@ -281,8 +443,43 @@ func (dao *genericDao[T]) save(ctx context.Context, tx *sql.Tx, e T) error {
 	return dao.saveChildren(ctx, tx, e)
 }
 
+// update runs the compare-and-swap update of the entity, which fails with ErrVersionMismatch if the stored version
+// isn't the previous version anymore
+func (dao *genericDao[T]) update(ctx context.Context, tx *sql.Tx, e T, version uuid.UUID) error {
+	args := dao.versionedUpdateArgs(e, version)
+	if placeholders, ok := countPlaceholders(dao.updateByIdAndVersionStmt.Query); ok && placeholders != len(args) {
+		slog.ErrorContext(ctx, "Update placeholders don't match updateArgs and the version", "placeholders", placeholders, "args", len(args))
+		return fmt.Errorf("gosql: update statement has %d placeholders for %d updateArgs and the version", placeholders, len(args)-1)
+	}
+	res, err := dao.updateByIdAndVersionStmt.Exec(ctx, tx, args...)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to update entity", "id", e.GetID(), "error", err)
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get rows affected by update", "id", e.GetID(), "error", err)
+		return err
+	}
+	if affected == 0 {
+		slog.ErrorContext(ctx, "Version mismatch during update, entity was modified concurrently", "id", e.GetID(), "expected", version)
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// versionedUpdateArgs returns the arguments of the compare-and-swap update, binding the previous version
+// to the previous_version parameter of statements with named parameters or after the update arguments otherwise
+func (dao *genericDao[T]) versionedUpdateArgs(e T, version uuid.UUID) []any {
+	if parseNamedQuery(dao.updateByIdAndVersionStmt.Query).named {
+		return append(dao.updateArgs(e), sql.Named("previous_version", version))
+	}
+	return append(dao.updateArgs(e), version)
+}
+
 // FindById retrieves an entity by its ID
-func (dao *genericDao[T]) FindById(ctx context.Context, id uuid.UUID) (T, error) {
+func (dao *genericDao[T]) FindById(ctx context.Context, id uuid.UUID) (_ T, err error) {
+	defer dao.wrapError(&err, "FindById")
 	slog.DebugContext(ctx, "Finding entity by ID", "id", id)
 	return QueryWithTx(ctx, dao.db, RO, func(ctx context.Context, tx *sql.Tx) (T, error) {
 		return dao.findById(ctx, tx, id)
@@ -292,27 +489,96 @@ func (dao *genericDao[T]) FindById(ctx context.Context, id uuid.UUID) (T, error)
 func (dao *genericDao[T]) findById(ctx context.Context, tx *sql.Tx, id uuid.UUID) (T, error) {
 	res, err := dao.getByIdStmt.Query(ctx, tx, id)
 	if err != nil {
-		if err == sql.ErrNoRows {
+		if errors.Is(err, sql.ErrNoRows) {
 			slog.DebugContext(ctx, "Entity not found by ID", "id", id)
-		} else {
-			slog.ErrorContext(ctx, "Error finding entity by ID", "id", id, "error", err)
+			return res, dao.error("", ErrNotFound, id)
 		}
-		return res, err
+		slog.ErrorContext(ctx, "Error finding entity by ID", "id", id, "error", err)
+		return res, dao.error("", err, id)
 	}
 	slog.DebugContext(ctx, "Loading entity children", "id", id)
 	if err := dao.loadChildren(ctx, tx, res); err != nil {
 		slog.ErrorContext(ctx, "Error loading entity children", "id", id, "error", err)
-		return res, err
+		return res, dao.error("", err, id)
 	}
 	return res, nil
 }
 
+// FindByIds retrieves entities by their IDs in the order of the IDs, skipping the ones that don't exist
+func (dao *genericDao[T]) FindByIds(ctx context.Context, ids ...uuid.UUID) (_ []T, err error) {
+	defer dao.wrapError(&err, "FindByIds")
+	slog.DebugContext(ctx, "Finding entities by IDs", "count", len(ids))
+	if len(ids) == 0 {
+		return []T{}, nil
+	}
+	return QueryWithTx(ctx, dao.db, RO, func(ctx context.Context, tx *sql.Tx) ([]T, error) {
+		return dao.findByIds(ctx, tx, ids)
+	})
+}
+
+func (dao *genericDao[T]) findByIds(ctx context.Context, tx *sql.Tx, ids []uuid.UUID) ([]T, error) {
+	found, err := dao.lookupByIds(ctx, tx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]T, 0, len(found))
+	for _, id := range ids {
+		e, ok := found[id]
+		if !ok {
+			continue
+		}
+		// the same ID may be requested more than once
+		delete(found, id)
+		if err := dao.loadChildren(ctx, tx, e); err != nil {
+			slog.ErrorContext(ctx, "Error loading entity children", "id", id, "error", err)
+			return nil, err
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// lookupByIds returns the existing entities of the IDs by their IDs without loading their children
+func (dao *genericDao[T]) lookupByIds(ctx context.Context, tx *sql.Tx, ids []uuid.UUID) (map[uuid.UUID]T, error) {
+	found := make(map[uuid.UUID]T, len(ids))
+	if dao.findByIdsStmt != nil {
+		entities, err := dao.findByIdsStmt.Query(ctx, tx, ids)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error finding entities by IDs", "error", err)
+			return nil, err
+		}
+		for _, e := range entities {
+			found[e.GetID()] = e
+		}
+	} else {
+		for _, id := range ids {
+			e, err := dao.getByIdStmt.Query(ctx, tx, id)
+			if errors.Is(err, sql.ErrNoRows) {
+				continue
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "Error finding entity by ID", "id", id, "error", err)
+				return nil, err
+			}
+			found[id] = e
+		}
+	}
+	return found, nil
+}
+
 // FindOneByStmt retrieves a single entity using a custom SQL statement
-func (dao *genericDao[T]) FindOneByStmt(ctx context.Context, stmt *QueryOneStmt[T], args ...any) (T, error) {
+func (dao *genericDao[T]) FindOneByStmt(ctx context.Context, stmt *QueryOneStmt[T], args ...any) (_ T, err error) {
+	defer dao.wrapError(&err, "FindOneByStmt")
 	slog.DebugContext(ctx, "Finding one entity by statement", "args_count", len(args))
+	stmt = &QueryOneStmt[T]{BaseStmt: dao.baseStmt(&stmt.BaseStmt), NewReceiver: stmt.NewReceiver, Receive: stmt.Receive}
 	return QueryWithTx(ctx, dao.db, RO, func(ctx context.Context, tx *sql.Tx) (T, error) {
 		res, err := stmt.Query(ctx, tx, args...)
 		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				slog.DebugContext(ctx, "Entity not found by statement")
+				return res, ErrNotFound
+			}
 			slog.ErrorContext(ctx, "Error finding entity by statement", "error", err)
 			return res, err
 		}
@@ -326,9 +592,22 @@ func (dao *genericDao[T]) FindOneByStmt(ctx context.Context, stmt *QueryOneStmt[
 	})
 }
 
+// FindOneBy retrieves the first entity matching the specification
+func (dao *genericDao[T]) FindOneBy(ctx context.Context, spec Spec) (_ T, err error) {
+	defer dao.wrapError(&err, "FindOneBy")
+	slog.DebugContext(ctx, "Finding one entity by specification")
+	stmt, args, err := dao.listStmt(ctx, spec, nil, false)
+	if err != nil {
+		return Nil[T](), err
+	}
+	return dao.FindOneByStmt(ctx, &QueryOneStmt[T]{BaseStmt: stmt.BaseStmt, NewReceiver: stmt.NewReceiver, Receive: stmt.Receive}, args...)
+}
+
 // ListByStmt retrieves entities using a custom SQL statement
-func (dao *genericDao[T]) ListByStmt(ctx context.Context, stmt *QueryStmt[T], args ...any) ([]T, error) {
+func (dao *genericDao[T]) ListByStmt(ctx context.Context, stmt *QueryStmt[T], args ...any) (_ []T, err error) {
+	defer dao.wrapError(&err, "ListByStmt")
 	slog.DebugContext(ctx, "Listing entities by statement", "args_count", len(args))
+	stmt = dao.queryStmt(stmt)
 	return QueryWithTx(ctx, dao.db, RO, func(ctx context.Context, tx *sql.Tx) ([]T, error) {
 		res, err := stmt.Query(ctx, tx, args...)
 		if err != nil {
@@ -347,32 +626,95 @@ func (dao *genericDao[T]) ListByStmt(ctx context.Context, stmt *QueryStmt[T], ar
 	})
 }
 
-// ListAll retrieves all entities
-func (dao *genericDao[T]) ListAll(ctx context.Context) ([]T, error) {
-	slog.DebugContext(ctx, "Listing all entities")
-	return QueryWithTx(ctx, dao.db, RO, func(ctx context.Context, tx *sql.Tx) ([]T, error) {
-		res, err := dao.listAllStmt.Query(ctx, tx)
+// ListBy retrieves entities matching the specification, optionally in the given sort order
+// A nil specification matches all entities
+func (dao *genericDao[T]) ListBy(ctx context.Context, spec Spec, sort ...Sort) (_ []T, err error) {
+	defer dao.wrapError(&err, "ListBy")
+	slog.DebugContext(ctx, "Listing entities by specification", "sort", sort)
+	stmt, args, err := dao.listStmt(ctx, spec, sort, false)
+	if err != nil {
+		return nil, err
+	}
+	return dao.ListByStmt(ctx, stmt, args...)
+}
+
+// ListAll retrieves all entities, optionally in the given sort order
+func (dao *genericDao[T]) ListAll(ctx context.Context, sort ...Sort) (_ []T, err error) {
+	defer dao.wrapError(&err, "ListAll")
+	slog.DebugContext(ctx, "Listing all entities", "sort", sort)
+	return dao.ListBy(ctx, nil, sort...)
+}
+
+// CountBy counts entities matching the specification, a nil specification matches all entities
+func (dao *genericDao[T]) CountBy(ctx context.Context, spec Spec) (_ int, err error) {
+	defer dao.wrapError(&err, "CountBy")
+	slog.DebugContext(ctx, "Counting entities by specification")
+	stmt, args, err := dao.countStmt(ctx, spec)
+	if err != nil {
+		return 0, err
+	}
+	return QueryWithTx(ctx, dao.db, RO, func(ctx context.Context, tx *sql.Tx) (int, error) {
+		res, err := stmt.Query(ctx, tx, args...)
 		if err != nil {
-			slog.ErrorContext(ctx, "Error listing all entities", "error", err)
-			return nil, err
-		}
-		slog.DebugContext(ctx, "Loading children for all entities", "count", len(res))
-		for _, e := range res {
-			item := e
-			if err := dao.loadChildren(ctx, tx, item); err != nil {
-				slog.ErrorContext(ctx, "Error loading entity children", "id", item.GetID(), "error", err)
-				return nil, err
-			}
+			slog.ErrorContext(ctx, "Error counting entities by specification", "error", err)
+			return 0, err
 		}
 		return res, nil
 	})
 }
 
+// StreamByStmt returns a sequence of entities retrieved using a custom SQL statement
+// The entities are scanned and their children are loaded lazily within a read-only transaction that stays open
+// while the caller ranges over the sequence. Loading children while rows are open requires driver support
+// for multiple active result sets on a connection
+func (dao *genericDao[T]) StreamByStmt(ctx context.Context, stmt *QueryStmt[T], args ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		slog.DebugContext(ctx, "Streaming entities by statement", "args_count", len(args))
+		stmt := dao.queryStmt(stmt)
+		err := ExecWithTx(ctx, dao.db, RO, func(ctx context.Context, tx *sql.Tx) error {
+			for item, err := range stmt.Stream(ctx, tx, args...) {
+				if err != nil {
+					slog.ErrorContext(ctx, "Error streaming entities by statement", "error", err)
+					return err
+				}
+				if err := dao.loadChildren(ctx, tx, item); err != nil {
+					slog.ErrorContext(ctx, "Error loading entity children", "id", item.GetID(), "error", err)
+					return err
+				}
+				if !yield(item, nil) {
+					return nil
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			yield(Nil[T](), dao.error("StreamByStmt", err, uuid.Nil))
+		}
+	}
+}
+
+// StreamAll returns a sequence of all entities, optionally in the given sort order, see StreamByStmt
+func (dao *genericDao[T]) StreamAll(ctx context.Context, sort ...Sort) iter.Seq2[T, error] {
+	slog.DebugContext(ctx, "Streaming all entities", "sort", sort)
+	stmt, _, err := dao.listStmt(ctx, nil, sort, false)
+	if err != nil {
+		return func(yield func(T, error) bool) {
+			yield(Nil[T](), dao.error("StreamAll", err, uuid.Nil))
+		}
+	}
+	return dao.StreamByStmt(ctx, stmt)
+}
+
 // ListPageByStmt retrieves a paginated list of entities using a custom SQL statement
-func (dao *genericDao[T]) ListPageByStmt(ctx context.Context, stmt *QueryPageStmt[T], paging Paging, args ...any) (Page[T], error) {
+func (dao *genericDao[T]) ListPageByStmt(ctx context.Context, stmt *QueryPageStmt[T], paging Paging, args ...any) (_ Page[T], err error) {
+	defer dao.wrapError(&err, "ListPageByStmt")
 	slog.DebugContext(ctx, "Listing page of entities by statement", "paging", paging, "args_count", len(args))
+	stmt = &QueryPageStmt[T]{
+		CountStmt: &QueryValStmt[int]{BaseStmt: dao.baseStmt(&stmt.CountStmt.BaseStmt)},
+		QueryStmt: dao.queryStmt(stmt.QueryStmt),
+	}
 	return QueryWithTx(ctx, dao.db, RO, func(ctx context.Context, tx *sql.Tx) (Page[T], error) {
-		res, err := stmt.QueryPage(ctx, tx, paging, args...)
+		res, err := stmt.queryPage(ctx, tx, paging, dao.getPagingPolicy(), args...)
 		if err != nil {
 			slog.ErrorContext(ctx, "Error listing page of entities by statement", "error", err)
 			return Page[T]{}, err
@@ -389,146 +731,590 @@ func (dao *genericDao[T]) ListPageByStmt(ctx context.Context, stmt *QueryPageStm
 	})
 }
 
-// ListPage retrieves a paginated list of all entities
-func (dao *genericDao[T]) ListPage(ctx context.Context, paging Paging) (Page[T], error) {
-	slog.DebugContext(ctx, "Listing page of all entities", "paging", paging)
-	return QueryWithTx(ctx, dao.db, RO, func(ctx context.Context, tx *sql.Tx) (Page[T], error) {
-		res, err := dao.listAllPageStmt.QueryPage(ctx, tx, paging)
+// ListPageBy retrieves a paginated list of entities matching the specification, optionally in the given sort order
+// A nil specification matches all entities. Pages of a filtered listing are only stable if a sort order is given
+func (dao *genericDao[T]) ListPageBy(ctx context.Context, spec Spec, paging Paging, sort ...Sort) (_ Page[T], err error) {
+	defer dao.wrapError(&err, "ListPageBy")
+	slog.DebugContext(ctx, "Listing page of entities by specification", "paging", paging, "sort", sort)
+	queryStmt, args, err := dao.listStmt(ctx, spec, sort, true)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	countStmt, _, err := dao.countStmt(ctx, spec)
+	if err != nil {
+		return Page[T]{}, err
+	}
+	return dao.ListPageByStmt(ctx, &QueryPageStmt[T]{CountStmt: countStmt, QueryStmt: queryStmt}, paging, args...)
+}
+
+// ListPage retrieves a paginated list of all entities, optionally in the given sort order
+func (dao *genericDao[T]) ListPage(ctx context.Context, paging Paging, sort ...Sort) (_ Page[T], err error) {
+	defer dao.wrapError(&err, "ListPage")
+	slog.DebugContext(ctx, "Listing page of all entities", "paging", paging, "sort", sort)
+	return dao.ListPageBy(ctx, nil, paging, sort...)
+}
+
+// ListAfterByStmt retrieves a keyset-paginated list of entities after the cursor using a custom SQL statement
+func (dao *genericDao[T]) ListAfterByStmt(ctx context.Context, stmt *QueryCursorStmt[T], cursor Cursor, limit int, args ...any) (_ CursorPage[T], err error) {
+	defer dao.wrapError(&err, "ListAfterByStmt")
+	slog.DebugContext(ctx, "Listing entities after cursor by statement", "limit", limit, "args_count", len(args))
+	stmt = &QueryCursorStmt[T]{
+		BaseStmt:    dao.baseStmt(&stmt.BaseStmt),
+		KeyColumns:  stmt.KeyColumns,
+		Desc:        stmt.Desc,
+		Key:         stmt.Key,
+		NewReceiver: stmt.NewReceiver,
+		Receive:     stmt.Receive,
+	}
+	return QueryWithTx(ctx, dao.db, RO, func(ctx context.Context, tx *sql.Tx) (CursorPage[T], error) {
+		res, err := stmt.queryAfter(ctx, tx, cursor, limit, dao.getPagingPolicy(), args...)
 		if err != nil {
-			slog.ErrorContext(ctx, "Error listing page of all entities", "error", err)
-			return Page[T]{}, err
+			slog.ErrorContext(ctx, "Error listing entities after cursor by statement", "error", err)
+			return CursorPage[T]{}, err
 		}
-		slog.DebugContext(ctx, "Loading children for page of all entities", "count", len(res.Items))
+		slog.DebugContext(ctx, "Loading children for entities after cursor", "count", len(res.Items))
 		for _, e := range res.Items {
 			item := e
 			if err := dao.loadChildren(ctx, tx, item); err != nil {
 				slog.ErrorContext(ctx, "Error loading entity children", "id", item.GetID(), "error", err)
-				return Page[T]{}, err
+				return CursorPage[T]{}, err
 			}
 		}
 		return res, nil
 	})
 }
 
+// ListAfter retrieves a keyset-paginated list of all entities after the cursor
+func (dao *genericDao[T]) ListAfter(ctx context.Context, cursor Cursor, limit int) (_ CursorPage[T], err error) {
+	defer dao.wrapError(&err, "ListAfter")
+	if dao.listAllCursorStmt == nil {
+		slog.ErrorContext(ctx, "listAllCursorStmt is not configured")
+		return CursorPage[T]{}, errors.New("gosql: listAllCursorStmt is not configured")
+	}
+	return dao.ListAfterByStmt(ctx, dao.listAllCursorStmt, cursor, limit)
+}
+
 // Delete removes entities from the database
-func (dao *genericDao[T]) Delete(ctx context.Context, entities ...T) error {
+// In the versioned delete mode the entities' versions must match the stored ones
+func (dao *genericDao[T]) Delete(ctx context.Context, entities ...T) (err error) {
+	defer dao.wrapError(&err, "Delete")
 	slog.DebugContext(ctx, "Deleting entities", "count", len(entities))
 	if len(entities) == 0 {
 		return nil
 	}
 
-	return ExecWithTx(ctx, dao.db, RW, func(ctx context.Context, tx *sql.Tx) error {
+	var missing []uuid.UUID
+	err = ExecWithTx(ctx, dao.db, RW, func(ctx context.Context, tx *sql.Tx) error {
+		missing = make([]uuid.UUID, 0)
 		for _, e := range entities {
-			entity := e
-			slog.DebugContext(ctx, "Deleting entity by id", "id", entity.GetID())
-			if err := dao.deleteByIdStmt.Exec(ctx, tx, entity.GetID()); err != nil {
-				slog.ErrorContext(ctx, "Error deleting entity", "id", entity.GetID(), "error", err)
-				return err
+			deleted, err := dao.delete(ctx, tx, e)
+			if err != nil {
+				return dao.error("", err, e.GetID())
+			}
+			if !deleted {
+				if missing, err = dao.notDeleted(ctx, e.GetID(), missing); err != nil {
+					return dao.error("", err, e.GetID())
+				}
 			}
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	return dao.missingIdsError(missing)
+}
+
+// delete removes the entity by its ID, or by its ID and version in the versioned delete mode,
+// and reports whether the entity existed
+func (dao *genericDao[T]) delete(ctx context.Context, tx *sql.Tx, e T) (bool, error) {
+	if dao.deleteByIdAndVersionStmt == nil {
+		return dao.deleteById(ctx, tx, e.GetID())
+	}
+
+	slog.DebugContext(ctx, "Deleting entity by id and version", "id", e.GetID(), "version", e.GetVersion())
+	res, err := dao.deleteByIdAndVersionStmt.Exec(ctx, tx, dao.versionedDeleteArgs(e)...)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error deleting entity", "id", e.GetID(), "error", err)
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get rows affected by delete", "id", e.GetID(), "error", err)
+		return false, err
+	}
+	if affected > 0 {
+		return true, nil
+	}
+
+	// nothing was deleted, either because the entity doesn't exist or because it was modified
+	if _, err := dao.getByIdStmt.Query(ctx, tx, e.GetID()); errors.Is(err, sql.ErrNoRows) {
+		slog.DebugContext(ctx, "Entity not found for delete", "id", e.GetID())
+		return false, nil
+	} else if err != nil {
+		slog.ErrorContext(ctx, "Error finding entity after failed delete", "id", e.GetID(), "error", err)
+		return false, err
+	}
+	slog.ErrorContext(ctx, "Version mismatch during delete", "id", e.GetID(), "version", e.GetVersion())
+	return false, ErrVersionMismatch
+}
+
+// versionedDeleteArgs returns the arguments of the versioned delete, binding the ID and the version
+// to the id and version parameters of statements with named parameters or positionally otherwise
+func (dao *genericDao[T]) versionedDeleteArgs(e T) []any {
+	if parseNamedQuery(dao.deleteByIdAndVersionStmt.Query).named {
+		return []any{sql.Named("id", e.GetID()), sql.Named("version", e.GetVersion())}
+	}
+	return []any{e.GetID(), e.GetVersion()}
+}
+
+// checkVersion reports whether the entity exists and fails with ErrVersionMismatch if its stored version differs,
+// so that the children of a stale entity aren't deleted before the versioned delete of the entity fails
+func (dao *genericDao[T]) checkVersion(ctx context.Context, tx *sql.Tx, e T) (bool, error) {
+	stored, err := dao.getByIdStmt.Query(ctx, tx, e.GetID())
+	if errors.Is(err, sql.ErrNoRows) {
+		slog.DebugContext(ctx, "Entity not found for delete", "id", e.GetID())
+		return false, nil
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error finding entity for delete", "id", e.GetID(), "error", err)
+		return false, err
+	}
+	if stored.GetVersion() != e.GetVersion() {
+		slog.ErrorContext(ctx, "Version mismatch during delete", "id", e.GetID(), "expected", stored.GetVersion(), "actual", e.GetVersion())
+		return false, ErrVersionMismatch
+	}
+	return true, nil
+}
+
+// deleteById removes the entity by its ID and reports whether it existed
+func (dao *genericDao[T]) deleteById(ctx context.Context, tx *sql.Tx, id uuid.UUID) (bool, error) {
+	slog.DebugContext(ctx, "Deleting entity by id", "id", id)
+	res, err := dao.deleteByIdStmt.Exec(ctx, tx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Error deleting entity", "id", id, "error", err)
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get rows affected by delete", "id", id, "error", err)
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// notDeleted handles an entity that didn't exist when deleting it, which is collected if missing entities are reported
+// and fails the versioned delete otherwise
+func (dao *genericDao[T]) notDeleted(ctx context.Context, id uuid.UUID, missing []uuid.UUID) ([]uuid.UUID, error) {
+	if dao.reportMissing {
+		return append(missing, id), nil
+	}
+	if dao.deleteByIdAndVersionStmt != nil {
+		slog.ErrorContext(ctx, "Entity not found for delete", "id", id)
+		return missing, ErrNotFound
+	}
+	slog.DebugContext(ctx, "Entity not found for delete", "id", id)
+	return missing, nil
+}
+
+// missingIdsError returns the error reporting the missing IDs, nil if there are none or they aren't reported
+func (dao *genericDao[T]) missingIdsError(missing []uuid.UUID) error {
+	if !dao.reportMissing || len(missing) == 0 {
+		return nil
+	}
+	return &MissingIdsError{Ids: missing}
 }
 
 // DeleteCascade removes entities and their children from the database
-func (dao *genericDao[T]) DeleteCascade(ctx context.Context, entities ...T) error {
+// In the versioned delete mode the entities' versions must match the stored ones
+func (dao *genericDao[T]) DeleteCascade(ctx context.Context, entities ...T) (err error) {
+	defer dao.wrapError(&err, "DeleteCascade")
 	slog.DebugContext(ctx, "Deleting entities with cascade", "count", len(entities))
 	if len(entities) == 0 {
 		return nil
 	}
-	return ExecWithTx(ctx, dao.db, RW, func(ctx context.Context, tx *sql.Tx) error {
-		return dao.deleteCascade(ctx, tx, entities...)
+	var missing []uuid.UUID
+	err = ExecWithTx(ctx, dao.db, RW, func(ctx context.Context, tx *sql.Tx) error {
+		var err error
+		missing, err = dao.deleteCascade(ctx, tx, entities...)
+		return err
 	})
+	if err != nil {
+		return err
+	}
+	return dao.missingIdsError(missing)
 }
 
-func (dao *genericDao[T]) deleteCascade(ctx context.Context, tx *sql.Tx, entities ...T) error {
+// deleteCascade removes the entities after their children and returns the IDs of the entities that didn't exist
+func (dao *genericDao[T]) deleteCascade(ctx context.Context, tx *sql.Tx, entities ...T) ([]uuid.UUID, error) {
 	slog.DebugContext(ctx, "Deleting entities after children", "count", len(entities))
-	if len(entities) == 0 {
-		return nil
-	}
+	missing := make([]uuid.UUID, 0)
 	for _, e := range entities {
 		entity := e
+		if dao.deleteByIdAndVersionStmt != nil {
+			exists, err := dao.checkVersion(ctx, tx, entity)
+			if err != nil {
+				return nil, dao.error("", err, entity.GetID())
+			}
+			if !exists {
+				if missing, err = dao.notDeleted(ctx, entity.GetID(), missing); err != nil {
+					return nil, dao.error("", err, entity.GetID())
+				}
+				continue
+			}
+		}
 		slog.DebugContext(ctx, "Deleting entity children", "id", entity.GetID())
 		if err := dao.deleteChildren(ctx, tx, entity); err != nil {
 			slog.ErrorContext(ctx, "Error deleting entity children", "id", entity.GetID(), "error", err)
-			return err
+			return nil, dao.error("", err, entity.GetID())
 		}
-		if err := dao.deleteByIdStmt.Exec(ctx, tx, entity.GetID()); err != nil {
-			slog.ErrorContext(ctx, "Error deleting entity", "id", entity.GetID(), "error", err)
-			return err
+		deleted, err := dao.delete(ctx, tx, entity)
+		if err != nil {
+			return nil, dao.error("", err, entity.GetID())
+		}
+		if !deleted {
+			if missing, err = dao.notDeleted(ctx, entity.GetID(), missing); err != nil {
+				return nil, dao.error("", err, entity.GetID())
+			}
 		}
 	}
-	return nil
+	return missing, nil
 }
 
-// DeleteByIds removes entities by their IDs
-func (dao *genericDao[T]) DeleteByIds(ctx context.Context, ids ...uuid.UUID) error {
+// DeleteByIds removes entities by their IDs regardless of their versions
+func (dao *genericDao[T]) DeleteByIds(ctx context.Context, ids ...uuid.UUID) (err error) {
+	defer dao.wrapError(&err, "DeleteByIds")
 	slog.DebugContext(ctx, "Deleting entities by IDs", "count", len(ids))
 	if len(ids) == 0 {
 		return nil
 	}
-	return ExecWithTx(ctx, dao.db, RW, func(ctx context.Context, tx *sql.Tx) error {
-		for _, id := range ids {
-			if err := dao.deleteByIdStmt.Exec(ctx, tx, id); err != nil {
-				slog.ErrorContext(ctx, "Error deleting entity", "id", id, "error", err)
+	ids = uniqueIds(ids)
+	var missing []uuid.UUID
+	err = ExecWithTx(ctx, dao.db, RW, func(ctx context.Context, tx *sql.Tx) error {
+		missing = make([]uuid.UUID, 0)
+		if dao.deleteByIdsStmt != nil {
+			if dao.reportMissing {
+				// the rows affected by a single statement don't tell which IDs are missing
+				found, err := dao.lookupByIds(ctx, tx, ids)
+				if err != nil {
+					return err
+				}
+				for _, id := range ids {
+					if _, ok := found[id]; !ok {
+						missing = append(missing, id)
+					}
+				}
+			}
+			if _, err := dao.deleteByIdsStmt.Exec(ctx, tx, ids); err != nil {
+				slog.ErrorContext(ctx, "Error deleting entities by IDs", "error", err)
 				return err
 			}
+			return nil
+		}
+		for _, id := range ids {
+			deleted, err := dao.deleteById(ctx, tx, id)
+			if err != nil {
+				return dao.error("", err, id)
+			}
+			if !deleted {
+				missing = append(missing, id)
+			}
 		}
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+	return dao.missingIdsError(missing)
 }
 
-// DeleteByIdsCascade removes entities and their children by the entities' IDs
-func (dao *genericDao[T]) DeleteByIdsCascade(ctx context.Context, ids ...uuid.UUID) error {
+// DeleteByIdsCascade removes entities and their children by the entities' IDs, skipping the IDs that don't exist
+func (dao *genericDao[T]) DeleteByIdsCascade(ctx context.Context, ids ...uuid.UUID) (err error) {
+	defer dao.wrapError(&err, "DeleteByIdsCascade")
 	slog.DebugContext(ctx, "Deleting entities by IDs with cascade", "count", len(ids))
 	if len(ids) == 0 {
 		return nil
 	}
-	return ExecWithTx(ctx, dao.db, RW, func(ctx context.Context, tx *sql.Tx) error {
-		entities := make([]T, 0, len(ids))
+	ids = uniqueIds(ids)
+	var missing []uuid.UUID
+	err = ExecWithTx(ctx, dao.db, RW, func(ctx context.Context, tx *sql.Tx) error {
+		entities, err := dao.findByIds(ctx, tx, ids)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error listing entities for cascade delete", "error", err)
+			return err
+		}
+		found := make(map[uuid.UUID]struct{}, len(entities))
+		for _, e := range entities {
+			found[e.GetID()] = struct{}{}
+		}
+		missing = make([]uuid.UUID, 0)
 		for _, id := range ids {
-			entity, err := dao.FindById(ctx, id)
+			if _, ok := found[id]; !ok {
+				missing = append(missing, id)
+			}
+		}
+		_, err = dao.deleteCascade(ctx, tx, entities...)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return dao.missingIdsError(missing)
+}
+
+// uniqueIds returns the IDs without duplicates in the order of their first occurrence
+func uniqueIds(ids []uuid.UUID) []uuid.UUID {
+	seen := make(map[uuid.UUID]struct{}, len(ids))
+	res := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			res = append(res, id)
+		}
+	}
+	return res
+}
+
+// DeleteBy removes entities matching the specification like Delete, so their children aren't deleted
+// A nil specification or one matching all rows, e.g. an empty And, is rejected with ErrInvalidFilter
+func (dao *genericDao[T]) DeleteBy(ctx context.Context, spec Spec) (err error) {
+	defer dao.wrapError(&err, "DeleteBy")
+	slog.DebugContext(ctx, "Deleting entities by specification")
+	if matchesAll(spec) {
+		slog.ErrorContext(ctx, "Specification for delete doesn't restrict the deleted entities")
+		return fmt.Errorf("%w: empty specification for delete", ErrInvalidFilter)
+	}
+	stmt, args, err := dao.listStmt(ctx, spec, nil, false)
+	if err != nil {
+		return err
+	}
+
+	var missing []uuid.UUID
+	err = ExecWithTx(ctx, dao.db, RW, func(ctx context.Context, tx *sql.Tx) error {
+		entities, err := stmt.Query(ctx, tx, args...)
+		if err != nil {
+			slog.ErrorContext(ctx, "Error listing entities for delete by specification", "error", err)
+			return err
+		}
+		missing = make([]uuid.UUID, 0)
+		for _, e := range entities {
+			deleted, err := dao.delete(ctx, tx, e)
 			if err != nil {
-				slog.ErrorContext(ctx, "Error listing entities for cascade delete", "error", err)
-				return err
+				return dao.error("", err, e.GetID())
 			}
-			if !IsNil(entity) {
-				entities = append(entities, entity)
+			if !deleted {
+				if missing, err = dao.notDeleted(ctx, e.GetID(), missing); err != nil {
+					return dao.error("", err, e.GetID())
+				}
 			}
 		}
-		return dao.deleteCascade(ctx, tx, entities...)
+		return nil
 	})
+	if err != nil {
+		return err
+	}
+	return dao.missingIdsError(missing)
+}
+
+// error returns err as an *Error of the DAO's operation on the entity with the ID, nil if err is nil
+// An empty op keeps the operation of a nested error and uuid.Nil stands for an operation not specific to an entity
+func (dao *genericDao[T]) error(op string, err error, id uuid.UUID) error {
+	if op != "" {
+		op = "dao." + op
+	}
+	return wrapError(err, op, "", dao.entityType, id)
+}
+
+// wrapError replaces the error returned by the DAO's operation with an *Error of the operation
+func (dao *genericDao[T]) wrapError(err *error, op string) {
+	*err = dao.error(op, *err, uuid.Nil)
+}
+
+func (dao *genericDao[T]) getPagingPolicy() *PagingPolicy {
+	if dao.pagingPolicy == nil {
+		return DefaultPagingPolicy
+	}
+	return dao.pagingPolicy
+}
+
+// baseStmt returns a copy of the statement that uses the DAO's statement cache and dialect unless they are set,
+// so that statements created by callers are executed like the DAO's own statements
+func (dao *genericDao[T]) baseStmt(stmt *BaseStmt) BaseStmt {
+	copied := stmt.derive(stmt.Query, stmt.Cache)
+	if copied.stmtCache == nil {
+		copied.stmtCache = dao.stmtCache
+	}
+	if copied.dialect == nil {
+		copied.dialect = dao.dialect
+	}
+	return copied
+}
+
+func (dao *genericDao[T]) queryStmt(stmt *QueryStmt[T]) *QueryStmt[T] {
+	return &QueryStmt[T]{BaseStmt: dao.baseStmt(&stmt.BaseStmt), NewReceiver: stmt.NewReceiver, Receive: stmt.Receive}
+}
+
+// listStmt returns the statement that lists entities matching the specification in the sort order,
+// with the dialect's LIMIT and OFFSET clause if paged, along with the arguments of the specification
+// The configured statements are returned if neither a specification nor a sort order is given. Statements generated
+// for a sort order are kept in the DAO's statement cache and removed from it along with the DAO, while filtered ones
+// are prepared for every call since their text depends on the specification
+func (dao *genericDao[T]) listStmt(ctx context.Context, spec Spec, sort []Sort, paged bool) (*QueryStmt[T], []any, error) {
+	unsorted := dao.listAllStmt
+	if paged {
+		unsorted = dao.listAllPageStmt.QueryStmt
+	}
+	if spec == nil && len(sort) == 0 {
+		return unsorted, nil, nil
+	}
+
+	query := dao.listQuery()
+	var args []any
+	if spec != nil {
+		where, specArgs, err := spec.toSQL(dao.filterColumns)
+		if err != nil {
+			slog.ErrorContext(ctx, "Invalid specification", "error", err)
+			return nil, nil, err
+		}
+		query += " WHERE " + where
+		args = specArgs
+	}
+	if len(sort) > 0 {
+		clause, err := orderBy(dao.sortColumns, sort)
+		if err != nil {
+			slog.ErrorContext(ctx, "Invalid sort order", "sort", sort, "error", err)
+			return nil, nil, err
+		}
+		query += " ORDER BY " + clause
+	} else if paged {
+		// the pagination clauses of some dialects, e.g. OFFSET and FETCH of SQL Server, require an ORDER BY clause
+		query += " ORDER BY (SELECT NULL)"
+	}
+	if paged {
+		query += " " + unsorted.getDialect().LimitOffset()
+	}
+
+	stmt := &QueryStmt[T]{
+		BaseStmt:    unsorted.derive(query, unsorted.Cache && spec == nil),
+		NewReceiver: unsorted.NewReceiver,
+		Receive:     unsorted.Receive,
+	}
+	return stmt, args, nil
+}
+
+// listQuery returns the query that the statements generated for specifications and sort orders select from
+func (dao *genericDao[T]) listQuery() string {
+	return "SELECT * FROM (" + dao.listAllStmt.BaseStmt.Query + ") AS gosql_list"
 }
 
-// Close closes all prepared statements in the DAO
+// countStmt returns the statement that counts entities matching the specification, along with the arguments of the specification
+func (dao *genericDao[T]) countStmt(ctx context.Context, spec Spec) (*QueryValStmt[int], []any, error) {
+	if spec == nil {
+		return dao.listAllPageStmt.CountStmt, nil, nil
+	}
+	where, args, err := spec.toSQL(dao.filterColumns)
+	if err != nil {
+		slog.ErrorContext(ctx, "Invalid specification", "error", err)
+		return nil, nil, err
+	}
+	query := "SELECT COUNT(*) FROM (" + dao.listAllStmt.BaseStmt.Query + ") AS gosql_list WHERE " + where
+	return &QueryValStmt[int]{BaseStmt: BaseStmt{Query: query, dialect: dao.listAllStmt.dialect}}, args, nil
+}
+
+// baseStmts returns all statements of the DAO
+func (dao *genericDao[T]) baseStmts() []*BaseStmt {
+	stmts := []*BaseStmt{
+		&dao.insertStmt.BaseStmt,
+		&dao.updateStmt.BaseStmt,
+		&dao.getByIdStmt.BaseStmt,
+		&dao.listAllStmt.BaseStmt,
+		&dao.listAllPageStmt.CountStmt.BaseStmt,
+		&dao.listAllPageStmt.QueryStmt.BaseStmt,
+		&dao.deleteByIdStmt.BaseStmt,
+	}
+	if dao.listAllCursorStmt != nil {
+		stmts = append(stmts, &dao.listAllCursorStmt.BaseStmt)
+	}
+	if dao.findByIdsStmt != nil {
+		stmts = append(stmts, &dao.findByIdsStmt.BaseStmt)
+	}
+	if dao.deleteByIdsStmt != nil {
+		stmts = append(stmts, &dao.deleteByIdsStmt.BaseStmt)
+	}
+	if dao.updateByIdAndVersionStmt != nil {
+		stmts = append(stmts, &dao.updateByIdAndVersionStmt.BaseStmt)
+	}
+	if dao.deleteByIdAndVersionStmt != nil {
+		stmts = append(stmts, &dao.deleteByIdAndVersionStmt.BaseStmt)
+	}
+	return stmts
+}
+
+// Close closes all prepared statements in the DAO and removes them from the statement cache
 // This should be called when the DAO is no longer needed to free up resources
-func (dao *genericDao[T]) Close(ctx context.Context) error {
+func (dao *genericDao[T]) Close(ctx context.Context) (err error) {
+	defer dao.wrapError(&err, "Close")
 	slog.DebugContext(ctx, "Closing GenericDao prepared statements")
 	errs := make([]error, 0)
-	if err := dao.insertStmt.Close(ctx); err != nil {
+	if err := dao.insertStmt.close(ctx, dao.db); err != nil {
 		slog.Error("Failed to close insert statement", "error", err)
 		errs = append(errs, err)
 	}
-	if err := dao.updateStmt.Close(ctx); err != nil {
+	if err := dao.updateStmt.close(ctx, dao.db); err != nil {
 		slog.ErrorContext(ctx, "Failed to close update statement", "error", err)
 		errs = append(errs, err)
 	}
-	if err := dao.getByIdStmt.Close(ctx); err != nil {
+	if err := dao.getByIdStmt.close(ctx, dao.db); err != nil {
 		slog.ErrorContext(ctx, "Failed to close getById statement", "error", err)
 		errs = append(errs, err)
 	}
-	if err := dao.listAllStmt.Close(ctx); err != nil {
+	if err := dao.listAllStmt.close(ctx, dao.db); err != nil {
 		slog.ErrorContext(ctx, "Failed to close listAll statement", "error", err)
 		errs = append(errs, err)
 	}
-	if err := dao.listAllPageStmt.Close(ctx); err != nil {
+	if err := dao.listAllPageStmt.close(ctx, dao.db); err != nil {
 		slog.ErrorContext(ctx, "Failed to close listAllPage statement", "error", err)
 		errs = append(errs, err)
 	}
-	if err := dao.deleteByIdStmt.Close(ctx); err != nil {
+	if dao.listAllCursorStmt != nil {
+		if err := dao.listAllCursorStmt.close(ctx, dao.db); err != nil {
+			slog.ErrorContext(ctx, "Failed to close listAllCursorStmt statement", "error", err)
+			errs = append(errs, err)
+		}
+	}
+	if err := dao.deleteByIdStmt.close(ctx, dao.db); err != nil {
 		slog.ErrorContext(ctx, "Failed to close deleteByIds statement", "error", err)
 		errs = append(errs, err)
 	}
+	if dao.findByIdsStmt != nil {
+		if err := dao.findByIdsStmt.close(ctx, dao.db); err != nil {
+			slog.ErrorContext(ctx, "Failed to close findByIds statement", "error", err)
+			errs = append(errs, err)
+		}
+	}
+	if dao.deleteByIdsStmt != nil {
+		if err := dao.deleteByIdsStmt.close(ctx, dao.db); err != nil {
+			slog.ErrorContext(ctx, "Failed to close deleteByIds statement", "error", err)
+			errs = append(errs, err)
+		}
+	}
+	if dao.updateByIdAndVersionStmt != nil {
+		if err := dao.updateByIdAndVersionStmt.close(ctx, dao.db); err != nil {
+			slog.ErrorContext(ctx, "Failed to close updateByIdAndVersion statement", "error", err)
+			errs = append(errs, err)
+		}
+	}
+	if dao.deleteByIdAndVersionStmt != nil {
+		if err := dao.deleteByIdAndVersionStmt.close(ctx, dao.db); err != nil {
+			slog.ErrorContext(ctx, "Failed to close deleteByIdAndVersion statement", "error", err)
+			errs = append(errs, err)
+		}
+	}
+	prefix := parseNamedQuery(dao.listQuery()).rebind(dao.listAllStmt.getDialect())
+	if err := dao.listAllStmt.getStmtCache().removePrefix(ctx, dao.db, prefix); err != nil {
+		slog.ErrorContext(ctx, "Failed to close sorted listAll statements", "error", err)
+		errs = append(errs, err)
+	}
 	if len(errs) > 0 {
 		return errors.Join(errs...)
 	}
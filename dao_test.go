@@ -3,6 +3,11 @@ package gosql
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/google/uuid"
@@ -15,8 +20,8 @@ var (
 
 type Student struct {
 	GenericEntity
-	Name       string
-	Department *Department
+	Name       string      `db:"name"`
+	Department *Department `db:"department_id,ref=id"`
 }
 
 func (s *Student) Equals(another any) bool {
@@ -32,7 +37,7 @@ func (s *Student) Equals(another any) bool {
 
 type Department struct {
 	GenericEntity
-	Name string
+	Name string `db:"name"`
 }
 
 func (d *Department) Equals(another any) bool {
@@ -47,7 +52,16 @@ func (d *Department) Equals(another any) bool {
 }
 
 func initDB(t *testing.T) *sql.DB {
-	db, err := sql.Open("sqlite3", ":memory:")
+	return openDB(t, ":memory:")
+}
+
+// initDBWithOptions opens a database file, so that concurrent connections see the same database
+func initDBWithOptions(t *testing.T, options string) *sql.DB {
+	return openDB(t, filepath.Join(t.TempDir(), "test.db")+"?"+options)
+}
+
+func openDB(t *testing.T, dsn string) *sql.DB {
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		t.Fatalf("Failed to open sqlite3 database: %v", err)
 	}
@@ -80,6 +94,14 @@ func initDB(t *testing.T) *sql.DB {
 }
 
 func newDepartmentDao(t *testing.T, db *sql.DB) Dao[*Department] {
+	departmentDao, err := newDepartmentDaoBuilder(db).Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create DAO: %v", err)
+	}
+	return departmentDao
+}
+
+func newDepartmentDaoBuilder(db *sql.DB) DaoBuilder[*Department] {
 	// SQL statements for Department operations
 	const (
 		insertSQL      = `INSERT INTO departments (id, name, version) VALUES (?, ?, ?)`
@@ -91,10 +113,10 @@ func newDepartmentDao(t *testing.T, db *sql.DB) Dao[*Department] {
 		deleteByIDSQL  = `DELETE FROM departments WHERE id = ?`
 	)
 
-	// Create DAO instance
+	// Create DAO builder
 	newReceiver := func() *Department { return &Department{} }
 	receive := func(d *Department) []any { return []any{&d.ID, &d.Name, &d.Version} }
-	departmentDao, err := DaoBuilder[*Department]{
+	return DaoBuilder[*Department]{
 		DB:          db,
 		InsertStmt:  &DaoExecStmt{Query: insertSQL, Cache: false},
 		UpdateStmt:  &DaoExecStmt{Query: updateSQL, Cache: false},
@@ -104,6 +126,12 @@ func newDepartmentDao(t *testing.T, db *sql.DB) Dao[*Department] {
 			QueryStmt: &DaoQueryStmt[*Department]{Query: listAllPageSQL, Cache: true},
 			CountStmt: &DaoQueryValStmt[int]{Query: countAllSQL, Cache: true},
 		},
+		ListAllCursorStmt: &DaoQueryCursorStmt[*Department]{
+			Query:      listAllSQL,
+			Cache:      true,
+			KeyColumns: []string{"name", "id"},
+			Key:        func(d *Department) []any { return []any{d.Name, d.ID} },
+		},
 		DeleteByIdStmt: &DaoExecStmt{Query: deleteByIDSQL, Cache: false},
 		NewReceiver:    newReceiver,
 		Receive:        receive,
@@ -112,12 +140,7 @@ func newDepartmentDao(t *testing.T, db *sql.DB) Dao[*Department] {
 		SaveChildren:   func(ctx context.Context, tx *sql.Tx, e *Department) error { return nil },
 		LoadChildren:   func(ctx context.Context, tx *sql.Tx, e *Department) error { return nil },
 		DeleteChildren: func(ctx context.Context, tx *sql.Tx, e *Department) error { return nil },
-	}.Build(ctx)
-
-	if err != nil {
-		t.Fatalf("Failed to create DAO: %v", err)
 	}
-	return departmentDao
 }
 
 func newStudentDao(t *testing.T, db *sql.DB, departmentDao Dao[*Department]) Dao[*Student] {
@@ -281,7 +304,7 @@ func TestDepartmentDao(t *testing.T) {
 
 	// Verify deletion
 	fetchedDept, err = departmentDao.FindById(ctx, dept.ID)
-	if err != nil && err != sql.ErrNoRows {
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		t.Fatalf("Unexpected error when fetching deleted department: %v", err)
 	}
 	if fetchedDept != nil {
@@ -293,7 +316,7 @@ func TestDepartmentDao(t *testing.T) {
 	dept2Copy.SetVersion(uuid.New()) // Change version to force mismatch
 	dept2Copy.Name = "Natural Science"
 	err = departmentDao.Save(ctx, &dept2Copy)
-	if err != ErrVersionMismatch {
+	if !errors.Is(err, ErrVersionMismatch) {
 		t.Errorf("Expected ErrVersionMismatch, got %v", err)
 	}
 
@@ -481,7 +504,7 @@ func TestStudentDao(t *testing.T) {
 
 	// Verify deletion
 	fetchedStudent, err = studentDao.FindById(ctx, student.ID)
-	if err != nil && err != sql.ErrNoRows {
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		t.Fatalf("Unexpected error when fetching deleted student: %v", err)
 	}
 	if fetchedStudent != nil {
@@ -493,7 +516,7 @@ func TestStudentDao(t *testing.T) {
 	student2Copy.SetVersion(uuid.New()) // Change version to force mismatch
 	student2Copy.Name = "Bob Johnson"
 	err = studentDao.Save(ctx, &student2Copy)
-	if err != ErrVersionMismatch {
+	if !errors.Is(err, ErrVersionMismatch) {
 		t.Errorf("Expected ErrVersionMismatch, got %v", err)
 	}
 
@@ -569,7 +592,7 @@ func TestStudentDao(t *testing.T) {
 
 	// Verify department was also deleted
 	deletedDept, err := departmentDao.FindById(ctx, cascadeDept.ID)
-	if err != nil && err != sql.ErrNoRows {
+	if err != nil && !errors.Is(err, ErrNotFound) {
 		t.Fatalf("Unexpected error when fetching deleted department: %v", err)
 	}
 	if deletedDept != nil {
@@ -581,6 +604,949 @@ func TestStudentDao(t *testing.T) {
 	}
 }
 
+func TestDepartmentDaoPageMetadata(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	departmentDao := newDepartmentDao(t, db)
+	defer departmentDao.Close(ctx)
+
+	for _, name := range []string{"A", "B", "C", "D", "E"} {
+		if err := departmentDao.Save(ctx, &Department{Name: name}); err != nil {
+			t.Fatalf("Failed to save department: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		paging   Paging
+		expected Page[*Department]
+		items    int
+	}{
+		{
+			name:     "First page",
+			paging:   Paging{PageNum: 1, PageSize: 2},
+			expected: Page[*Department]{TotalPages: 3, TotalItems: 5, PageNum: 1, PageSize: 2, HasNext: true, HasPrev: false},
+			items:    2,
+		},
+		{
+			name:     "Last page",
+			paging:   Paging{PageNum: 3, PageSize: 2},
+			expected: Page[*Department]{TotalPages: 3, TotalItems: 5, PageNum: 3, PageSize: 2, HasNext: false, HasPrev: true},
+			items:    1,
+		},
+		{
+			name:     "Normalized paging",
+			paging:   Paging{PageNum: 0, PageSize: 0},
+			expected: Page[*Department]{TotalPages: 1, TotalItems: 5, PageNum: 1, PageSize: 20, HasNext: false, HasPrev: false},
+			items:    5,
+		},
+		{
+			name:     "Skipped count with next page",
+			paging:   Paging{PageNum: 2, PageSize: 2, SkipCount: true},
+			expected: Page[*Department]{TotalPages: -1, TotalItems: -1, PageNum: 2, PageSize: 2, HasNext: true, HasPrev: true},
+			items:    2,
+		},
+		{
+			name:     "Skipped count on last page",
+			paging:   Paging{PageNum: 3, PageSize: 2, SkipCount: true},
+			expected: Page[*Department]{TotalPages: -1, TotalItems: -1, PageNum: 3, PageSize: 2, HasNext: false, HasPrev: true},
+			items:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, err := departmentDao.ListPage(ctx, tt.paging)
+			if err != nil {
+				t.Fatalf("Failed to get page: %v", err)
+			}
+			if len(page.Items) != tt.items {
+				t.Errorf("Expected %d items, got %d", tt.items, len(page.Items))
+			}
+			page.Items = nil
+			if !reflect.DeepEqual(page, tt.expected) {
+				t.Errorf("Expected page %+v, got %+v", tt.expected, page)
+			}
+		})
+	}
+}
+
+func TestDepartmentDaoPagingPolicy(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	builder := newDepartmentDaoBuilder(db)
+	builder.PagingPolicy = &PagingPolicy{DefaultPageSize: 2, MaxPageSize: 3, Reject: true}
+	rejectingDao, err := builder.Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create DAO: %v", err)
+	}
+	defer rejectingDao.Close(ctx)
+
+	builder = newDepartmentDaoBuilder(db)
+	builder.PagingPolicy = &PagingPolicy{DefaultPageSize: 2, MaxPageSize: 3}
+	clampingDao, err := builder.Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create DAO: %v", err)
+	}
+	defer clampingDao.Close(ctx)
+
+	for _, name := range []string{"A", "B", "C", "D", "E"} {
+		if err := clampingDao.Save(ctx, &Department{Name: name}); err != nil {
+			t.Fatalf("Failed to save department: %v", err)
+		}
+	}
+
+	// Unset paging falls back to the default page size
+	page, err := rejectingDao.ListPage(ctx, Paging{})
+	if err != nil {
+		t.Fatalf("Failed to get page: %v", err)
+	}
+	if len(page.Items) != 2 || page.PageSize != 2 {
+		t.Errorf("Expected 2 items of default page size, got %d items of page size %d", len(page.Items), page.PageSize)
+	}
+
+	// Oversized page is rejected or clamped
+	if _, err := rejectingDao.ListPage(ctx, Paging{PageNum: 1, PageSize: 10}); !errors.Is(err, ErrInvalidPaging) {
+		t.Errorf("Expected ErrInvalidPaging, got %v", err)
+	}
+	page, err = clampingDao.ListPage(ctx, Paging{PageNum: 1, PageSize: 10})
+	if err != nil {
+		t.Fatalf("Failed to get page: %v", err)
+	}
+	if len(page.Items) != 3 || page.PageSize != 3 {
+		t.Errorf("Expected 3 items of max page size, got %d items of page size %d", len(page.Items), page.PageSize)
+	}
+
+	// Negative page number is rejected or clamped
+	if _, err := rejectingDao.ListPage(ctx, Paging{PageNum: -1, PageSize: 2}); !errors.Is(err, ErrInvalidPaging) {
+		t.Errorf("Expected ErrInvalidPaging, got %v", err)
+	}
+	page, err = clampingDao.ListPage(ctx, Paging{PageNum: -1, PageSize: 2})
+	if err != nil {
+		t.Fatalf("Failed to get page: %v", err)
+	}
+	if page.PageNum != 1 {
+		t.Errorf("Expected page number 1, got %d", page.PageNum)
+	}
+
+	// Keyset pagination limit follows the same policy
+	if _, err := rejectingDao.ListAfter(ctx, "", 10); !errors.Is(err, ErrInvalidPaging) {
+		t.Errorf("Expected ErrInvalidPaging, got %v", err)
+	}
+	cursorPage, err := clampingDao.ListAfter(ctx, "", 10)
+	if err != nil {
+		t.Fatalf("Failed to list after cursor: %v", err)
+	}
+	if len(cursorPage.Items) != 3 {
+		t.Errorf("Expected 3 items, got %d", len(cursorPage.Items))
+	}
+}
+
+func TestDepartmentDaoSort(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	builder := newDepartmentDaoBuilder(db)
+	builder.SortColumns = map[string]string{"name": "name", "id": "id"}
+	departmentDao, err := builder.Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create DAO: %v", err)
+	}
+	defer departmentDao.Close(ctx)
+
+	for _, name := range []string{"B", "A", "D", "C", "A"} {
+		if err := departmentDao.Save(ctx, &Department{Name: name}); err != nil {
+			t.Fatalf("Failed to save department: %v", err)
+		}
+	}
+	names := func(departments []*Department) string {
+		res := ""
+		for _, d := range departments {
+			res += d.Name
+		}
+		return res
+	}
+
+	all, err := departmentDao.ListAll(ctx, Sort{Field: "name", Direction: Desc}, Sort{Field: "id"})
+	if err != nil {
+		t.Fatalf("Failed to list departments: %v", err)
+	}
+	if names(all) != "DCBAA" {
+		t.Errorf("Expected departments sorted as DCBAA, got %s", names(all))
+	}
+	if all[3].ID.String() > all[4].ID.String() {
+		t.Errorf("Expected departments with the same name to be sorted by id")
+	}
+
+	page, err := departmentDao.ListPage(ctx, Paging{PageNum: 2, PageSize: 2}, ParseSort("-name")...)
+	if err != nil {
+		t.Fatalf("Failed to get page: %v", err)
+	}
+	if names(page.Items) != "BA" || page.TotalItems != 5 {
+		t.Errorf("Expected page BA of 5 departments, got %s of %d", names(page.Items), page.TotalItems)
+	}
+
+	streamed := make([]*Department, 0)
+	for d, err := range departmentDao.StreamAll(ctx, Sort{Field: "name"}) {
+		if err != nil {
+			t.Fatalf("Failed to stream departments: %v", err)
+		}
+		streamed = append(streamed, d)
+	}
+	if names(streamed) != "AABCD" {
+		t.Errorf("Expected departments sorted as AABCD, got %s", names(streamed))
+	}
+
+	// Fields outside the whitelist are rejected rather than injected
+	if _, err := departmentDao.ListAll(ctx, Sort{Field: "version"}); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("Expected ErrInvalidSort, got %v", err)
+	}
+	if _, err := departmentDao.ListPage(ctx, Paging{}, Sort{Field: "name; DELETE FROM departments"}); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("Expected ErrInvalidSort, got %v", err)
+	}
+	for _, err := range departmentDao.StreamAll(ctx, Sort{Field: "name", Direction: "up"}) {
+		if !errors.Is(err, ErrInvalidSort) {
+			t.Errorf("Expected ErrInvalidSort, got %v", err)
+		}
+	}
+	if _, err := departmentDao.ListAll(ctx, Sort{Field: "name"}, Sort{Field: "name", Direction: Desc}); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("Expected ErrInvalidSort for a duplicate field, got %v", err)
+	}
+
+	// Statements generated for sort orders are cached until the DAO is closed
+	countSorted := func() int {
+		DefaultStmtCache.mu.Lock()
+		defer DefaultStmtCache.mu.Unlock()
+		count := 0
+		for key := range DefaultStmtCache.entries {
+			if key.db == db && strings.Contains(key.query, "ORDER BY") && strings.Contains(key.query, "gosql_list") {
+				count++
+			}
+		}
+		return count
+	}
+	if countSorted() == 0 {
+		t.Error("Expected statements for sort orders to be cached")
+	}
+	if err := departmentDao.Close(ctx); err != nil {
+		t.Fatalf("Failed to close DAO: %v", err)
+	}
+	if count := countSorted(); count != 0 {
+		t.Errorf("Expected statements for sort orders to be released, got %d", count)
+	}
+}
+
+func TestDepartmentDaoSpec(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	builder := newDepartmentDaoBuilder(db)
+	builder.SortColumns = map[string]string{"name": "name"}
+	builder.FilterColumns = map[string]string{"name": "name", "id": "id"}
+	departmentDao, err := builder.Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create DAO: %v", err)
+	}
+	defer departmentDao.Close(ctx)
+
+	departments := make(map[string]*Department)
+	for _, name := range []string{"Art", "Biology", "Chemistry", "Drama", "Economics"} {
+		department := &Department{Name: name}
+		if err := departmentDao.Save(ctx, department); err != nil {
+			t.Fatalf("Failed to save department: %v", err)
+		}
+		departments[name] = department
+	}
+	names := func(departments []*Department) string {
+		res := make([]string, 0, len(departments))
+		for _, d := range departments {
+			res = append(res, d.Name)
+		}
+		return strings.Join(res, ",")
+	}
+
+	spec := Or(Between("name", "B", "D"), Eq("id", departments["Economics"].ID))
+	list, err := departmentDao.ListBy(ctx, spec, Sort{Field: "name"})
+	if err != nil {
+		t.Fatalf("Failed to list departments: %v", err)
+	}
+	if names(list) != "Biology,Chemistry,Economics" {
+		t.Errorf("Expected Biology,Chemistry,Economics, got %s", names(list))
+	}
+
+	count, err := departmentDao.CountBy(ctx, Not(Like("name", "%a%")))
+	if err != nil {
+		t.Fatalf("Failed to count departments: %v", err)
+	}
+	// LIKE is case-insensitive in SQLite
+	if count != 3 {
+		t.Errorf("Expected 3 departments without 'a', got %d", count)
+	}
+
+	found, err := departmentDao.FindOneBy(ctx, And(Like("name", "D%"), Not(IsNull("id"))))
+	if err != nil {
+		t.Fatalf("Failed to find department: %v", err)
+	}
+	if !found.Equals(departments["Drama"]) {
+		t.Errorf("Expected Drama, got %s", found.Name)
+	}
+	if _, err := departmentDao.FindOneBy(ctx, Eq("name", "Zoology")); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	page, err := departmentDao.ListPageBy(ctx, Not(Eq("name", "Art")), Paging{PageNum: 2, PageSize: 3}, Sort{Field: "name"})
+	if err != nil {
+		t.Fatalf("Failed to get page: %v", err)
+	}
+	if names(page.Items) != "Economics" || page.TotalItems != 4 || page.TotalPages != 2 {
+		t.Errorf("Expected page Economics of 4 departments, got %s of %d", names(page.Items), page.TotalItems)
+	}
+	page, err = departmentDao.ListPageBy(ctx, Not(Eq("name", "Art")), Paging{PageNum: 1, PageSize: 10})
+	if err != nil {
+		t.Fatalf("Failed to get unsorted page: %v", err)
+	}
+	if len(page.Items) != 4 || page.TotalItems != 4 {
+		t.Errorf("Expected unsorted page of 4 departments, got %d of %d", len(page.Items), page.TotalItems)
+	}
+
+	if _, err := departmentDao.ListBy(ctx, Eq("version", uuid.New())); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("Expected ErrInvalidFilter, got %v", err)
+	}
+	if err := departmentDao.DeleteBy(ctx, Eq("name; DROP TABLE departments", 1)); !errors.Is(err, ErrInvalidFilter) {
+		t.Errorf("Expected ErrInvalidFilter, got %v", err)
+	}
+	// Specifications that don't restrict the deleted entities are rejected instead of deleting the whole table
+	for _, spec := range []Spec{nil, And(), And(And())} {
+		if err := departmentDao.DeleteBy(ctx, spec); !errors.Is(err, ErrInvalidFilter) {
+			t.Errorf("Expected ErrInvalidFilter for %v, got %v", spec, err)
+		}
+	}
+
+	if err := departmentDao.DeleteBy(ctx, In("name", "Art", "Drama", "Music")); err != nil {
+		t.Fatalf("Failed to delete departments: %v", err)
+	}
+	list, err = departmentDao.ListAll(ctx, Sort{Field: "name"})
+	if err != nil {
+		t.Fatalf("Failed to list departments: %v", err)
+	}
+	if names(list) != "Biology,Chemistry,Economics" {
+		t.Errorf("Expected Biology,Chemistry,Economics after delete, got %s", names(list))
+	}
+}
+
+func TestDepartmentDaoNamedParams(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	builder := newDepartmentDaoBuilder(db)
+	builder.InsertStmt = &DaoExecStmt{Query: `INSERT INTO departments (id, name, version) VALUES (:id, :name, :version)`, Cache: true}
+	builder.UpdateStmt = &DaoExecStmt{Query: `UPDATE departments SET name = :name, version = :version WHERE id = :id`, Cache: true}
+	builder.InsertArgs = func(d *Department) []any { return []any{d} }
+	builder.UpdateArgs = func(d *Department) []any { return []any{d} }
+	builder.DeleteByIdAndVersionStmt = &DaoExecStmt{Query: `DELETE FROM departments WHERE version = :version AND id = :id`, Cache: true}
+	departmentDao, err := builder.Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create DAO: %v", err)
+	}
+	defer departmentDao.Close(ctx)
+
+	for _, name := range []string{"Art", "Biology", "Botany", "Chemistry"} {
+		if err := departmentDao.Save(ctx, &Department{Name: name}); err != nil {
+			t.Fatalf("Failed to save department: %v", err)
+		}
+	}
+	department, err := departmentDao.FindOneByStmt(ctx, &QueryOneStmt[*Department]{
+		BaseStmt:    BaseStmt{Query: `SELECT id, name, version FROM departments WHERE name = @name`},
+		NewReceiver: func() *Department { return &Department{} },
+		Receive:     func(d *Department) []any { return []any{&d.ID, &d.Name, &d.Version} },
+	}, sql.Named("name", "Art"))
+	if err != nil {
+		t.Fatalf("Failed to find department: %v", err)
+	}
+	department.Name = "Arts"
+	if err := departmentDao.Save(ctx, department); err != nil {
+		t.Fatalf("Failed to update department: %v", err)
+	}
+
+	stmt := &QueryPageStmt[*Department]{
+		CountStmt: &QueryValStmt[int]{BaseStmt: BaseStmt{Query: `SELECT COUNT(*) FROM departments WHERE name LIKE :prefix || '%'`}},
+		QueryStmt: &QueryStmt[*Department]{
+			BaseStmt:    BaseStmt{Query: `SELECT id, name, version FROM departments WHERE name LIKE :prefix || '%' ORDER BY name LIMIT ? OFFSET ?`},
+			NewReceiver: func() *Department { return &Department{} },
+			Receive:     func(d *Department) []any { return []any{&d.ID, &d.Name, &d.Version} },
+		},
+	}
+	for _, prefix := range []string{"A", "B"} {
+		page, err := departmentDao.ListPageByStmt(ctx, stmt, Paging{PageNum: 1, PageSize: 1}, map[string]any{"prefix": prefix})
+		if err != nil {
+			t.Fatalf("Failed to get page: %v", err)
+		}
+		if len(page.Items) != 1 || page.Items[0].Name[:1] != prefix {
+			t.Errorf("Expected a department starting with %s, got %+v", prefix, page.Items)
+		}
+		if prefix == "A" && (page.Items[0].Name != "Arts" || page.TotalItems != 1) {
+			t.Errorf("Expected updated department Arts only, got %s of %d", page.Items[0].Name, page.TotalItems)
+		}
+		if prefix == "B" && page.TotalItems != 2 {
+			t.Errorf("Expected 2 departments starting with B, got %d", page.TotalItems)
+		}
+	}
+
+	if _, err := departmentDao.ListPageByStmt(ctx, stmt, Paging{}, map[string]any{"prefix": "A", "suffix": "s"}); !errors.Is(err, ErrInvalidNamedArgs) {
+		t.Errorf("Expected ErrInvalidNamedArgs for unused name, got %v", err)
+	}
+	if _, err := departmentDao.ListPageByStmt(ctx, stmt, Paging{}, map[string]any{}); !errors.Is(err, ErrInvalidNamedArgs) {
+		t.Errorf("Expected ErrInvalidNamedArgs for missing name, got %v", err)
+	}
+
+	// The versioned delete binds the ID and the version by name
+	stale := &Department{GenericEntity: department.GenericEntity, Name: department.Name}
+	department.Name = "Art"
+	if err := departmentDao.Save(ctx, department); err != nil {
+		t.Fatalf("Failed to update department: %v", err)
+	}
+	if err := departmentDao.Delete(ctx, stale); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch for stale department, got %v", err)
+	}
+	if err := departmentDao.Delete(ctx, department); err != nil {
+		t.Fatalf("Failed to delete department: %v", err)
+	}
+	if _, err := departmentDao.FindById(ctx, department.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected deleted department to be gone, got %v", err)
+	}
+}
+
+func TestDepartmentDaoFindAndDeleteByIds(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	builder := newDepartmentDaoBuilder(db)
+	builder.FindByIdsStmt = &DaoQueryStmt[*Department]{Query: `SELECT id, name, version FROM departments WHERE id IN (?)`, Cache: true}
+	builder.DeleteByIdsStmt = &DaoExecStmt{Query: `DELETE FROM departments WHERE id IN (?)`, Cache: true}
+	batchDao, err := builder.Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create DAO: %v", err)
+	}
+	defer batchDao.Close(ctx)
+	fallbackDao := newDepartmentDao(t, db)
+	defer fallbackDao.Close(ctx)
+
+	ids := make([]uuid.UUID, 0)
+	for _, name := range []string{"A", "B", "C", "D", "E"} {
+		department := &Department{Name: name}
+		if err := batchDao.Save(ctx, department); err != nil {
+			t.Fatalf("Failed to save department: %v", err)
+		}
+		ids = append(ids, department.ID)
+	}
+	names := func(departments []*Department) string {
+		res := ""
+		for _, d := range departments {
+			res += d.Name
+		}
+		return res
+	}
+
+	for name, departmentDao := range map[string]Dao[*Department]{"batch": batchDao, "fallback": fallbackDao} {
+		found, err := departmentDao.FindByIds(ctx, ids[3], uuid.New(), ids[0], ids[3], ids[2])
+		if err != nil {
+			t.Fatalf("%s: failed to find departments: %v", name, err)
+		}
+		if names(found) != "DAC" {
+			t.Errorf("%s: expected DAC, got %s", name, names(found))
+		}
+		found, err = departmentDao.FindByIds(ctx)
+		if err != nil || len(found) != 0 {
+			t.Errorf("%s: expected no departments, got %d, %v", name, len(found), err)
+		}
+	}
+
+	if err := batchDao.DeleteByIds(ctx, ids[0], ids[1]); err != nil {
+		t.Fatalf("Failed to delete departments: %v", err)
+	}
+	if err := fallbackDao.DeleteByIds(ctx, ids[2]); err != nil {
+		t.Fatalf("Failed to delete departments: %v", err)
+	}
+	remaining, err := batchDao.ListAll(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list departments: %v", err)
+	}
+	if len(remaining) != 2 {
+		t.Errorf("Expected 2 remaining departments, got %d", len(remaining))
+	}
+
+	// expanded statements aren't cached, the cache only holds the statements with a fixed number of placeholders
+	if err := batchDao.Close(ctx); err != nil {
+		t.Fatalf("Failed to close DAO: %v", err)
+	}
+	if err := fallbackDao.Close(ctx); err != nil {
+		t.Fatalf("Failed to close DAO: %v", err)
+	}
+	DefaultStmtCache.mu.Lock()
+	defer DefaultStmtCache.mu.Unlock()
+	for key := range DefaultStmtCache.entries {
+		if key.db == db {
+			t.Errorf("Expected all statements to be removed from the cache, got %q", key.query)
+		}
+	}
+}
+
+func TestDepartmentDaoOptimisticLocking(t *testing.T) {
+	newLockingDaoBuilder := func(db *sql.DB) DaoBuilder[*Department] {
+		builder := newDepartmentDaoBuilder(db)
+		builder.UpdateByIdAndVersionStmt = &DaoExecStmt{Query: `UPDATE departments SET name = ?, version = ? WHERE id = ? AND version = ?`, Cache: true}
+		return builder
+	}
+
+	t.Run("Concurrent update between read and write", func(t *testing.T) {
+		newNamedDaoBuilder := func(db *sql.DB) DaoBuilder[*Department] {
+			builder := newDepartmentDaoBuilder(db)
+			builder.UpdateStmt = &DaoExecStmt{Query: `UPDATE departments SET name = :name, version = :version WHERE id = :id`}
+			builder.UpdateArgs = func(d *Department) []any { return []any{d} }
+			return builder
+		}
+		tests := []struct {
+			name    string
+			builder func(db *sql.DB) DaoBuilder[*Department]
+		}{
+			{name: "Positional parameters", builder: newLockingDaoBuilder},
+			{
+				name: "Named parameters",
+				builder: func(db *sql.DB) DaoBuilder[*Department] {
+					builder := newNamedDaoBuilder(db)
+					builder.UpdateByIdAndVersionStmt = &DaoExecStmt{
+						Query: `UPDATE departments SET name = :name, version = :version WHERE id = :id AND version = :previous_version`,
+					}
+					return builder
+				},
+			},
+			{name: "Derived from positional UpdateStmt", builder: newDepartmentDaoBuilder},
+			{name: "Derived from named UpdateStmt", builder: newNamedDaoBuilder},
+		}
+
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				db := initDB(t)
+				defer db.Close()
+
+				// The hook runs within the update's transaction after the entity is read,
+				// simulating a writer that commits between the read and the write
+				var concurrentVersion uuid.UUID
+				builder := tt.builder(db)
+				builder.LoadChildren = func(ctx context.Context, tx *sql.Tx, d *Department) error {
+					if concurrentVersion == uuid.Nil {
+						return nil
+					}
+					_, err := tx.ExecContext(ctx, "UPDATE departments SET name = 'Concurrent', version = ? WHERE id = ?", concurrentVersion, d.ID)
+					return err
+				}
+				departmentDao, err := builder.Build(ctx)
+				if err != nil {
+					t.Fatalf("Failed to create DAO: %v", err)
+				}
+				defer departmentDao.Close(ctx)
+
+				department := &Department{Name: "Math"}
+				if err := departmentDao.Save(ctx, department); err != nil {
+					t.Fatalf("Failed to save department: %v", err)
+				}
+				version := department.Version
+
+				concurrentVersion = uuid.New()
+				department.Name = "Physics"
+				err = departmentDao.Save(ctx, department)
+				concurrentVersion = uuid.Nil
+				if !errors.Is(err, ErrVersionMismatch) {
+					t.Fatalf("Expected ErrVersionMismatch, got %v", err)
+				}
+				if department.Version != version {
+					t.Errorf("Expected the version to be restored to %v, got %v", version, department.Version)
+				}
+				stored, err := departmentDao.FindById(ctx, department.ID)
+				if err != nil {
+					t.Fatalf("Failed to find department: %v", err)
+				}
+				// The simulated update is rolled back with the failed transaction, the stale update must not be applied either
+				if stored.Name != "Math" || stored.Version != version {
+					t.Errorf("Expected the department to be unchanged, got %+v", stored)
+				}
+			})
+		}
+	})
+
+	t.Run("Concurrent writers", func(t *testing.T) {
+		// Immediate transactions serialize the writers instead of failing them on lock upgrades,
+		// the writers after the first one update with a stale version, which only the compare-and-swap update detects
+		db := initDBWithOptions(t, "_busy_timeout=5000&_txlock=immediate")
+		defer db.Close()
+		departmentDao, err := newDepartmentDaoBuilder(db).Build(ctx)
+		if err != nil {
+			t.Fatalf("Failed to create DAO: %v", err)
+		}
+		defer departmentDao.Close(ctx)
+
+		department := &Department{Name: "Math"}
+		if err := departmentDao.Save(ctx, department); err != nil {
+			t.Fatalf("Failed to save department: %v", err)
+		}
+
+		const writers = 8
+		copies := make([]*Department, writers)
+		for i := range copies {
+			copies[i] = &Department{GenericEntity: department.GenericEntity, Name: department.Name}
+		}
+		errs := make([]error, writers)
+		var start, done sync.WaitGroup
+		start.Add(1)
+		for i := range copies {
+			done.Add(1)
+			go func() {
+				defer done.Done()
+				start.Wait()
+				copies[i].Name = "Writer " + string(rune('A'+i))
+				errs[i] = departmentDao.Save(ctx, copies[i])
+			}()
+		}
+		start.Done()
+		done.Wait()
+
+		winner := -1
+		for i, err := range errs {
+			switch {
+			case err == nil && winner >= 0:
+				t.Errorf("Expected a single successful writer, got %d and %d", winner, i)
+			case err == nil:
+				winner = i
+			case !errors.Is(err, ErrVersionMismatch):
+				t.Errorf("Expected ErrVersionMismatch for writer %d, got %v", i, err)
+			}
+		}
+		if winner < 0 {
+			t.Fatalf("Expected a successful writer")
+		}
+
+		stored, err := departmentDao.FindById(ctx, department.ID)
+		if err != nil {
+			t.Fatalf("Failed to find department: %v", err)
+		}
+		if stored.Name != copies[winner].Name || stored.Version != copies[winner].Version {
+			t.Errorf("Expected the winner's update %+v to be stored, got %+v", copies[winner], stored)
+		}
+	})
+}
+
+func TestDepartmentDaoVersionedDelete(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	builder := newDepartmentDaoBuilder(db)
+	builder.DeleteByIdAndVersionStmt = &DaoExecStmt{Query: "DELETE FROM departments WHERE id = ? AND version = ?", Cache: true}
+	builder.FilterColumns = map[string]string{"name": "name"}
+	deletedChildren := make([]uuid.UUID, 0)
+	builder.DeleteChildren = func(ctx context.Context, tx *sql.Tx, d *Department) error {
+		deletedChildren = append(deletedChildren, d.ID)
+		return nil
+	}
+	departmentDao, err := builder.Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create DAO: %v", err)
+	}
+	defer departmentDao.Close(ctx)
+
+	math, physics := &Department{Name: "Math"}, &Department{Name: "Physics"}
+	if err := departmentDao.Save(ctx, math, physics); err != nil {
+		t.Fatalf("Failed to save departments: %v", err)
+	}
+	stale := &Department{GenericEntity: math.GenericEntity, Name: math.Name}
+	math.Name = "Mathematics"
+	if err := departmentDao.Save(ctx, math); err != nil {
+		t.Fatalf("Failed to update department: %v", err)
+	}
+
+	if err := departmentDao.Delete(ctx, stale); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch for stale entity, got %v", err)
+	}
+	if err := departmentDao.DeleteCascade(ctx, stale); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch for stale entity with cascade, got %v", err)
+	}
+	// The version is checked before the children are deleted
+	if len(deletedChildren) != 0 {
+		t.Errorf("Expected children of stale entity to be kept, got deleted children of %v", deletedChildren)
+	}
+	if err := departmentDao.DeleteCascade(ctx, &Department{GenericEntity: GenericEntity{ID: uuid.New(), Version: uuid.New()}}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for missing entity with cascade, got %v", err)
+	}
+	if len(deletedChildren) != 0 {
+		t.Errorf("Expected no children of missing entity to be deleted, got deleted children of %v", deletedChildren)
+	}
+	if _, err := departmentDao.FindById(ctx, math.ID); err != nil {
+		t.Errorf("Expected the modified department to be kept, got %v", err)
+	}
+
+	missing := &Department{GenericEntity: GenericEntity{ID: uuid.New(), Version: uuid.New()}}
+	if err := departmentDao.Delete(ctx, missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for missing entity, got %v", err)
+	}
+
+	// A failed delete rolls back the whole batch
+	if err := departmentDao.Delete(ctx, physics, stale); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("Expected ErrVersionMismatch for batch with stale entity, got %v", err)
+	}
+	if count, err := departmentDao.CountBy(ctx, nil); err != nil || count != 2 {
+		t.Errorf("Expected 2 departments after rolled back delete, got %d, %v", count, err)
+	}
+
+	// DeleteBy deletes the matching entities like Delete, without their children
+	if err := departmentDao.DeleteBy(ctx, Eq("name", "Mathematics")); err != nil {
+		t.Errorf("Failed to delete current department: %v", err)
+	}
+	if len(deletedChildren) != 0 {
+		t.Errorf("Expected DeleteBy to keep children, got deleted children of %v", deletedChildren)
+	}
+	// DeleteByIds ignores versions
+	if err := departmentDao.DeleteByIds(ctx, physics.ID); err != nil {
+		t.Errorf("Failed to force delete department: %v", err)
+	}
+	if count, err := departmentDao.CountBy(ctx, nil); err != nil || count != 0 {
+		t.Errorf("Expected no departments left, got %d, %v", count, err)
+	}
+}
+
+func TestDepartmentDaoReportMissing(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	builder := newDepartmentDaoBuilder(db)
+	builder.ReportMissing = true
+	fallbackDao, err := builder.Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create DAO: %v", err)
+	}
+	defer fallbackDao.Close(ctx)
+	builder.DeleteByIdsStmt = &DaoExecStmt{Query: `DELETE FROM departments WHERE id IN (?)`}
+	builder.DeleteByIdAndVersionStmt = &DaoExecStmt{Query: "DELETE FROM departments WHERE id = ? AND version = ?"}
+	batchDao, err := builder.Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create DAO: %v", err)
+	}
+	defer batchDao.Close(ctx)
+	silentDao := newDepartmentDao(t, db)
+	defer silentDao.Close(ctx)
+
+	if _, err := silentDao.FindById(ctx, uuid.New()); !errors.Is(err, ErrNotFound) || !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected ErrNotFound wrapping sql.ErrNoRows, got %v", err)
+	}
+
+	save := func(names ...string) []*Department {
+		departments := make([]*Department, 0, len(names))
+		for _, name := range names {
+			departments = append(departments, &Department{Name: name})
+		}
+		if err := silentDao.Save(ctx, departments...); err != nil {
+			t.Fatalf("Failed to save departments: %v", err)
+		}
+		return departments
+	}
+	assertMissing := func(name string, err error, expected ...uuid.UUID) {
+		t.Helper()
+		var missingErr *MissingIdsError
+		if !errors.As(err, &missingErr) || !errors.Is(err, ErrNotFound) {
+			t.Fatalf("%s: expected MissingIdsError, got %v", name, err)
+		}
+		if !reflect.DeepEqual(missingErr.Ids, expected) {
+			t.Errorf("%s: expected missing IDs %v, got %v", name, expected, missingErr.Ids)
+		}
+	}
+	assertCount := func(name string, expected int) {
+		t.Helper()
+		if count, err := silentDao.CountBy(ctx, nil); err != nil || count != expected {
+			t.Errorf("%s: expected %d departments, got %d, %v", name, expected, count, err)
+		}
+	}
+
+	unknown := &Department{GenericEntity: GenericEntity{ID: uuid.New(), Version: uuid.New()}}
+	for name, departmentDao := range map[string]Dao[*Department]{"batch": batchDao, "fallback": fallbackDao} {
+		departments := save("A", "B", "C")
+		assertMissing(name+" Delete", departmentDao.Delete(ctx, departments[0], unknown), unknown.ID)
+		assertMissing(name+" DeleteCascade", departmentDao.DeleteCascade(ctx, unknown, departments[1]), unknown.ID)
+		assertCount(name, 1)
+
+		departments = save("D", "E")
+		missingID := uuid.New()
+		assertMissing(name+" DeleteByIds", departmentDao.DeleteByIds(ctx, missingID, departments[0].ID, missingID), missingID)
+		assertMissing(name+" DeleteByIdsCascade", departmentDao.DeleteByIdsCascade(ctx, departments[1].ID, missingID), missingID)
+		assertCount(name, 1)
+		if err := departmentDao.DeleteByIds(ctx, departments[1].ID); err == nil {
+			t.Errorf("%s: expected an error for deleted department", name)
+		}
+
+		remaining, err := departmentDao.ListAll(ctx)
+		if err != nil {
+			t.Fatalf("%s: failed to list departments: %v", name, err)
+		}
+		if err := departmentDao.DeleteByIds(ctx, remaining[0].ID); err != nil {
+			t.Errorf("%s: failed to delete existing department: %v", name, err)
+		}
+	}
+
+	// Missing entities are skipped silently unless they are reported
+	departments := save("F")
+	if err := silentDao.DeleteByIdsCascade(ctx, uuid.New(), departments[0].ID); err != nil {
+		t.Errorf("Expected missing IDs to be skipped, got %v", err)
+	}
+	if err := silentDao.Delete(ctx, unknown); err != nil {
+		t.Errorf("Expected missing entity to be skipped, got %v", err)
+	}
+	assertCount("silent", 0)
+}
+
+func TestStudentDaoStream(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	departmentDao := newDepartmentDao(t, db)
+	defer departmentDao.Close(ctx)
+	studentDao := newStudentDao(t, db, departmentDao)
+	defer studentDao.Close(ctx)
+
+	dept := &Department{Name: "Computer Science"}
+	if err := departmentDao.Save(ctx, dept); err != nil {
+		t.Fatalf("Failed to create department: %v", err)
+	}
+	students := []*Student{
+		{Name: "Student1", Department: dept},
+		{Name: "Student2", Department: dept},
+		{Name: "Student3", Department: dept},
+	}
+	if err := studentDao.Save(ctx, students...); err != nil {
+		t.Fatalf("Failed to save students: %v", err)
+	}
+
+	count := 0
+	for student, err := range studentDao.StreamAll(ctx) {
+		if err != nil {
+			t.Fatalf("Failed to stream students: %v", err)
+		}
+		if !student.Department.Equals(dept) {
+			t.Errorf("Expected department %v to be loaded, got %v", dept, student.Department)
+		}
+		count++
+	}
+	if count != 3 {
+		t.Errorf("Expected 3 students, got %d", count)
+	}
+
+	// Breaking early ends the transaction
+	byName := &QueryStmt[*Student]{
+		BaseStmt:    BaseStmt{Query: `SELECT id, name, department_id, version FROM students WHERE name <> ? ORDER BY name`},
+		NewReceiver: func() *Student { return &Student{Department: &Department{}} },
+		Receive:     func(s *Student) []any { return []any{&s.ID, &s.Name, &s.Department.ID, &s.Version} },
+	}
+	for student, err := range studentDao.StreamByStmt(ctx, byName, "Student1") {
+		if err != nil {
+			t.Fatalf("Failed to stream students: %v", err)
+		}
+		if student.Name != "Student2" {
+			t.Errorf("Expected Student2, got %s", student.Name)
+		}
+		break
+	}
+	if err := studentDao.Save(ctx, &Student{Name: "Student4", Department: dept}); err != nil {
+		t.Fatalf("Failed to save student after streaming: %v", err)
+	}
+}
+
+func TestDaoPropagation(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	departmentDao := newDepartmentDao(t, db)
+	defer departmentDao.Close(ctx)
+
+	// Mandatory propagation requires a caller-managed transaction
+	err := departmentDao.Save(WithPropagation(ctx, Mandatory), &Department{Name: "Mandatory"})
+	if !errors.Is(err, ErrNoTransaction) {
+		t.Errorf("Expected ErrNoTransaction, got %v", err)
+	}
+
+	err = ExecWithTx(ctx, db, RW, func(ctx context.Context, tx *sql.Tx) error {
+		if err := departmentDao.Save(WithPropagation(ctx, Mandatory), &Department{Name: "Mandatory"}); err != nil {
+			return err
+		}
+
+		// Never propagation must not run within the caller's transaction
+		if _, err := departmentDao.ListAll(WithPropagation(ctx, Never)); !errors.Is(err, ErrTransactionExists) {
+			t.Errorf("Expected ErrTransactionExists, got %v", err)
+		}
+
+		// A failed nested save is rolled back without discarding the outer transaction
+		failing := &Department{Name: "Failing"}
+		if err := departmentDao.Save(ctx, failing); err != nil {
+			return err
+		}
+		failing.Name = "Renamed"
+		failing.SetVersion(uuid.New())
+		if err := departmentDao.Save(WithPropagation(ctx, Nested), failing); !errors.Is(err, ErrVersionMismatch) {
+			t.Errorf("Expected ErrVersionMismatch, got %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to save departments within transaction: %v", err)
+	}
+
+	// Saving within a read-only transaction is rejected upfront
+	_, err = QueryWithTx(ctx, db, RO, func(ctx context.Context, tx *sql.Tx) (*Department, error) {
+		dept := &Department{Name: "Read-only"}
+		return dept, departmentDao.Save(ctx, dept)
+	})
+	if !errors.Is(err, ErrReadOnlyTransaction) {
+		t.Errorf("Expected ErrReadOnlyTransaction, got %v", err)
+	}
+
+	departments, err := departmentDao.ListAll(WithPropagation(ctx, Never))
+	if err != nil {
+		t.Fatalf("Failed to list departments: %v", err)
+	}
+	if len(departments) != 2 {
+		t.Errorf("Expected 2 departments, got %d", len(departments))
+	}
+}
+
+func TestDaoMultipleDatabases(t *testing.T) {
+	primary := initDB(t)
+	defer primary.Close()
+	reporting := initDB(t)
+	defer reporting.Close()
+
+	primaryDao := newDepartmentDao(t, primary)
+	defer primaryDao.Close(ctx)
+	reportingDao := newDepartmentDao(t, reporting)
+	defer reportingDao.Close(ctx)
+
+	err := ExecWithTx(ctx, primary, RW, func(ctx context.Context, tx *sql.Tx) error {
+		if err := primaryDao.Save(ctx, &Department{Name: "Primary"}); err != nil {
+			return err
+		}
+		return reportingDao.Save(ctx, &Department{Name: "Reporting"})
+	})
+	if err != nil {
+		t.Fatalf("Failed to save departments: %v", err)
+	}
+
+	for dao, expected := range map[Dao[*Department]]string{primaryDao: "Primary", reportingDao: "Reporting"} {
+		departments, err := dao.ListAll(ctx)
+		if err != nil {
+			t.Fatalf("Failed to list departments: %v", err)
+		}
+		if len(departments) != 1 || departments[0].Name != expected {
+			t.Errorf("Expected only department %s, got %v", expected, departments)
+		}
+	}
+}
+
 func TestDaoBuilderValidate(t *testing.T) {
 	// Set up SQLite database
 	db := initDB(t)
@@ -875,4 +1841,73 @@ func TestDaoBuilderValidate(t *testing.T) {
 	if err != nil {
 		t.Errorf("Expected no error for valid builder, got: %v", err)
 	}
+
+	// UpdateArgs isn't called on build, so it may rely on fields an empty entity doesn't have, e.g. nested pointers
+	builder := newDepartmentDaoBuilder(db)
+	builder.UpdateArgs = func(d *Department) []any {
+		if d.ID == uuid.Nil {
+			panic("UpdateArgs called on an empty entity")
+		}
+		return []any{d.Name, d.Version, d.ID}
+	}
+	if _, err := builder.Build(ctx); err != nil {
+		t.Errorf("Expected no error for UpdateArgs relying on a saved entity, got: %v", err)
+	}
+
+	// The compare-and-swap update can't be derived from update statements without a WHERE clause or ? placeholders
+	for _, update := range []string{"UPDATE departments SET name = ?", "UPDATE departments SET name = $1 WHERE id = $2"} {
+		builder := newDepartmentDaoBuilder(db)
+		builder.UpdateStmt = &DaoExecStmt{Query: update}
+		if _, err := builder.Build(ctx); err == nil {
+			t.Errorf("Expected builder error for %q, got nil", update)
+		}
+	}
+	builder = newDepartmentDaoBuilder(db)
+	builder.UpdateStmt = &DaoExecStmt{Query: "UPDATE departments SET name = $1 WHERE id = $2"}
+	builder.UpdateByIdAndVersionStmt = &DaoExecStmt{Query: "UPDATE departments SET name = $1 WHERE id = $2 AND version = $3"}
+	if _, err := builder.Build(ctx); err != nil {
+		t.Errorf("Expected no error for native placeholders with updateByIdAndVersionStmt, got: %v", err)
+	}
+}
+
+func TestDaoUpdatePlaceholders(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	// Placeholders of update statements must match UpdateArgs and the version, which is checked on update
+	// rather than on build, so that UpdateArgs isn't called on an empty entity
+	for _, tt := range []struct {
+		name, update, updateByIdAndVersion string
+	}{
+		{name: "version compared by updateStmt", update: "UPDATE departments SET name = ? WHERE id = ? AND version = ?"},
+		{name: "missing updateStmt placeholder", update: "UPDATE departments SET name = 'x' WHERE id = ?"},
+		{
+			name:                 "missing version placeholder",
+			update:               "UPDATE departments SET name = ? WHERE id = ?",
+			updateByIdAndVersion: "UPDATE departments SET name = ? WHERE id = ?",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			builder := newDepartmentDaoBuilder(db)
+			builder.UpdateStmt = &DaoExecStmt{Query: tt.update}
+			builder.UpdateArgs = func(d *Department) []any { return []any{d.Name, d.ID} }
+			if tt.updateByIdAndVersion != "" {
+				builder.UpdateByIdAndVersionStmt = &DaoExecStmt{Query: tt.updateByIdAndVersion}
+			}
+			departmentDao, err := builder.Build(ctx)
+			if err != nil {
+				t.Fatalf("Failed to create DAO: %v", err)
+			}
+			defer departmentDao.Close(ctx)
+
+			department := &Department{Name: tt.name}
+			if err := departmentDao.Save(ctx, department); err != nil {
+				t.Fatalf("Failed to save department: %v", err)
+			}
+			department.Name += " updated"
+			if err := departmentDao.Save(ctx, department); err == nil || errors.Is(err, ErrVersionMismatch) {
+				t.Errorf("Expected placeholder mismatch error, got %v", err)
+			}
+		})
+	}
 }
@@ -0,0 +1,256 @@
+package gosql
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Dialect abstracts the SQL syntax that differs between databases
+// Queries are written with ? or named placeholders, which are rebound to the dialect's placeholders at prepare time
+type Dialect interface {
+	// Name returns the name of the dialect
+	Name() string
+	// Placeholder returns the n-th positional placeholder, starting from 1
+	Placeholder(n int) string
+	// LimitOffset returns the clause that limits a sorted query to a page, with ? placeholders for LimitOffsetArgs
+	LimitOffset() string
+	// LimitOffsetArgs returns the arguments of the LimitOffset clause, which follow the arguments of the query
+	LimitOffsetArgs(limit, offset int) []any
+	// Quote quotes an identifier, e.g. a table or column name
+	Quote(identifier string) string
+	// Upsert generates a statement that inserts a row of the columns, with ? placeholders in the order of columns,
+	// or updates the rest of the columns if a row with the same key columns exists
+	Upsert(table string, columns, keyColumns []string) string
+	// SupportsReturning reports whether Returning clauses can be appended to INSERT, UPDATE and DELETE statements
+	SupportsReturning() bool
+	// Returning returns the clause that makes a statement return the columns of the affected rows,
+	// or an empty string if it isn't supported
+	Returning(columns []string) string
+}
+
+var (
+	// SQLite is the dialect of SQLite, RETURNING requires SQLite 3.35 or newer
+	SQLite Dialect = sqliteDialect{}
+	// Postgres is the dialect of PostgreSQL with $n placeholders
+	Postgres Dialect = postgresDialect{}
+	// MySQL is the dialect of MySQL and MariaDB
+	MySQL Dialect = mysqlDialect{}
+	// SQLServer is the dialect of Microsoft SQL Server with @pn placeholders and OFFSET/FETCH pagination
+	SQLServer Dialect = sqlServerDialect{}
+)
+
+// DefaultDialect is the dialect used by statements unless configured otherwise
+var DefaultDialect = SQLite
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return "sqlite"
+}
+
+func (sqliteDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (sqliteDialect) LimitOffset() string {
+	return "LIMIT ? OFFSET ?"
+}
+
+func (sqliteDialect) LimitOffsetArgs(limit, offset int) []any {
+	return []any{limit, offset}
+}
+
+func (sqliteDialect) Quote(identifier string) string {
+	return quote(identifier, `"`, `"`)
+}
+
+func (d sqliteDialect) Upsert(table string, columns, keyColumns []string) string {
+	return onConflictUpsert(d, table, columns, keyColumns)
+}
+
+func (sqliteDialect) SupportsReturning() bool {
+	return true
+}
+
+func (d sqliteDialect) Returning(columns []string) string {
+	return "RETURNING " + quoteAll(d, columns)
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return "postgres"
+}
+
+func (postgresDialect) Placeholder(n int) string {
+	return "$" + strconv.Itoa(n)
+}
+
+func (postgresDialect) LimitOffset() string {
+	return "LIMIT ? OFFSET ?"
+}
+
+func (postgresDialect) LimitOffsetArgs(limit, offset int) []any {
+	return []any{limit, offset}
+}
+
+func (postgresDialect) Quote(identifier string) string {
+	return quote(identifier, `"`, `"`)
+}
+
+func (d postgresDialect) Upsert(table string, columns, keyColumns []string) string {
+	return onConflictUpsert(d, table, columns, keyColumns)
+}
+
+func (postgresDialect) SupportsReturning() bool {
+	return true
+}
+
+func (d postgresDialect) Returning(columns []string) string {
+	return "RETURNING " + quoteAll(d, columns)
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string {
+	return "mysql"
+}
+
+func (mysqlDialect) Placeholder(int) string {
+	return "?"
+}
+
+func (mysqlDialect) LimitOffset() string {
+	return "LIMIT ? OFFSET ?"
+}
+
+func (mysqlDialect) LimitOffsetArgs(limit, offset int) []any {
+	return []any{limit, offset}
+}
+
+func (mysqlDialect) Quote(identifier string) string {
+	return quote(identifier, "`", "`")
+}
+
+func (d mysqlDialect) Upsert(table string, columns, keyColumns []string) string {
+	updates := make([]string, 0, len(columns))
+	for _, column := range nonKeyColumns(columns, keyColumns) {
+		updates = append(updates, d.Quote(column)+" = VALUES("+d.Quote(column)+")")
+	}
+	if len(updates) == 0 {
+		// keep the existing row
+		updates = append(updates, d.Quote(keyColumns[0])+" = "+d.Quote(keyColumns[0]))
+	}
+	return insertInto(d, table, columns) + " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+}
+
+func (mysqlDialect) SupportsReturning() bool {
+	return false
+}
+
+func (mysqlDialect) Returning([]string) string {
+	return ""
+}
+
+type sqlServerDialect struct{}
+
+func (sqlServerDialect) Name() string {
+	return "sqlserver"
+}
+
+func (sqlServerDialect) Placeholder(n int) string {
+	return "@p" + strconv.Itoa(n)
+}
+
+func (sqlServerDialect) LimitOffset() string {
+	return "OFFSET ? ROWS FETCH NEXT ? ROWS ONLY"
+}
+
+func (sqlServerDialect) LimitOffsetArgs(limit, offset int) []any {
+	return []any{offset, limit}
+}
+
+func (sqlServerDialect) Quote(identifier string) string {
+	return quote(identifier, "[", "]")
+}
+
+func (d sqlServerDialect) Upsert(table string, columns, keyColumns []string) string {
+	quoted := quoteAll(d, columns)
+	on := make([]string, 0, len(keyColumns))
+	for _, column := range keyColumns {
+		on = append(on, "target."+d.Quote(column)+" = source."+d.Quote(column))
+	}
+	updates := make([]string, 0, len(columns))
+	for _, column := range nonKeyColumns(columns, keyColumns) {
+		updates = append(updates, d.Quote(column)+" = source."+d.Quote(column))
+	}
+	values := make([]string, 0, len(columns))
+	for _, column := range columns {
+		values = append(values, "source."+d.Quote(column))
+	}
+
+	query := "MERGE INTO " + d.Quote(table) + " AS target USING (VALUES (" + placeholders(len(columns)) + ")) AS source (" + quoted + ")" +
+		" ON " + strings.Join(on, " AND ")
+	if len(updates) > 0 {
+		query += " WHEN MATCHED THEN UPDATE SET " + strings.Join(updates, ", ")
+	}
+	return query + " WHEN NOT MATCHED THEN INSERT (" + quoted + ") VALUES (" + strings.Join(values, ", ") + ");"
+}
+
+// SupportsReturning reports false, as the OUTPUT clause of SQL Server precedes the VALUES and WHERE clauses
+func (sqlServerDialect) SupportsReturning() bool {
+	return false
+}
+
+func (sqlServerDialect) Returning([]string) string {
+	return ""
+}
+
+// quote quotes an identifier, quoting every part of a qualified identifier and escaping closing quotes by doubling them
+func quote(identifier, open, close string) string {
+	parts := strings.Split(identifier, ".")
+	for i, part := range parts {
+		parts[i] = open + strings.ReplaceAll(part, close, close+close) + close
+	}
+	return strings.Join(parts, ".")
+}
+
+func quoteAll(d Dialect, identifiers []string) string {
+	quoted := make([]string, 0, len(identifiers))
+	for _, identifier := range identifiers {
+		quoted = append(quoted, d.Quote(identifier))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func insertInto(d Dialect, table string, columns []string) string {
+	return "INSERT INTO " + d.Quote(table) + " (" + quoteAll(d, columns) + ") VALUES (" + placeholders(len(columns)) + ")"
+}
+
+func nonKeyColumns(columns, keyColumns []string) []string {
+	res := make([]string, 0, len(columns))
+	for _, column := range columns {
+		if !slices.Contains(keyColumns, column) {
+			res = append(res, column)
+		}
+	}
+	return res
+}
+
+// onConflictUpsert generates an upsert with the ON CONFLICT clause shared by SQLite and Postgres
+func onConflictUpsert(d Dialect, table string, columns, keyColumns []string) string {
+	updates := make([]string, 0, len(columns))
+	for _, column := range nonKeyColumns(columns, keyColumns) {
+		updates = append(updates, d.Quote(column)+" = excluded."+d.Quote(column))
+	}
+	query := insertInto(d, table, columns) + " ON CONFLICT (" + quoteAll(d, keyColumns) + ")"
+	if len(updates) == 0 {
+		return query + " DO NOTHING"
+	}
+	return query + " DO UPDATE SET " + strings.Join(updates, ", ")
+}
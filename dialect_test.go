@@ -0,0 +1,164 @@
+package gosql
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDialectRebind(t *testing.T) {
+	query := "SELECT * FROM t WHERE a = :a AND b IN (?) AND c = '?' ORDER BY a"
+	tests := []struct {
+		dialect       Dialect
+		expectedQuery string
+	}{
+		{dialect: SQLite, expectedQuery: "SELECT * FROM t WHERE a = ? AND b IN (?, ?) AND c = '?' ORDER BY a"},
+		{dialect: Postgres, expectedQuery: "SELECT * FROM t WHERE a = $1 AND b IN ($2, $3) AND c = '?' ORDER BY a"},
+		{dialect: MySQL, expectedQuery: "SELECT * FROM t WHERE a = ? AND b IN (?, ?) AND c = '?' ORDER BY a"},
+		{dialect: SQLServer, expectedQuery: "SELECT * FROM t WHERE a = @p1 AND b IN (@p2, @p3) AND c = '?' ORDER BY a"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			res, args, _, err := parseNamedQuery(query).prepareArgs([]any{map[string]any{"a": 1}, []int{2, 3}}, tt.dialect)
+			if err != nil {
+				t.Fatalf("Failed to prepare arguments: %v", err)
+			}
+			if res != tt.expectedQuery {
+				t.Errorf("Expected query %q, got %q", tt.expectedQuery, res)
+			}
+			if !reflect.DeepEqual(args, []any{1, 2, 3}) {
+				t.Errorf("Expected args [1 2 3], got %v", args)
+			}
+		})
+	}
+}
+
+func TestDialectSQL(t *testing.T) {
+	columns := []string{"id", "name", "version"}
+	tests := []struct {
+		dialect             Dialect
+		expectedLimitOffset string
+		expectedArgs        []any
+		expectedQuote       string
+		expectedUpsert      string
+		expectedKeyUpsert   string
+		expectedReturning   string
+	}{
+		{
+			dialect:             SQLite,
+			expectedLimitOffset: "LIMIT ? OFFSET ?",
+			expectedArgs:        []any{10, 20},
+			expectedQuote:       `"public"."user ""x"""`,
+			expectedUpsert:      `INSERT INTO "users" ("id", "name", "version") VALUES (?, ?, ?) ON CONFLICT ("id") DO UPDATE SET "name" = excluded."name", "version" = excluded."version"`,
+			expectedKeyUpsert:   `INSERT INTO "users" ("id", "name", "version") VALUES (?, ?, ?) ON CONFLICT ("id", "name", "version") DO NOTHING`,
+			expectedReturning:   `RETURNING "id", "version"`,
+		},
+		{
+			dialect:             Postgres,
+			expectedLimitOffset: "LIMIT ? OFFSET ?",
+			expectedArgs:        []any{10, 20},
+			expectedQuote:       `"public"."user ""x"""`,
+			expectedUpsert:      `INSERT INTO "users" ("id", "name", "version") VALUES (?, ?, ?) ON CONFLICT ("id") DO UPDATE SET "name" = excluded."name", "version" = excluded."version"`,
+			expectedKeyUpsert:   `INSERT INTO "users" ("id", "name", "version") VALUES (?, ?, ?) ON CONFLICT ("id", "name", "version") DO NOTHING`,
+			expectedReturning:   `RETURNING "id", "version"`,
+		},
+		{
+			dialect:             MySQL,
+			expectedLimitOffset: "LIMIT ? OFFSET ?",
+			expectedArgs:        []any{10, 20},
+			expectedQuote:       "`public`.`user \"x\"`",
+			expectedUpsert:      "INSERT INTO `users` (`id`, `name`, `version`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `name` = VALUES(`name`), `version` = VALUES(`version`)",
+			expectedKeyUpsert:   "INSERT INTO `users` (`id`, `name`, `version`) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE `id` = `id`",
+		},
+		{
+			dialect:             SQLServer,
+			expectedLimitOffset: "OFFSET ? ROWS FETCH NEXT ? ROWS ONLY",
+			expectedArgs:        []any{20, 10},
+			expectedQuote:       `[public].[user "x"]`,
+			expectedUpsert: "MERGE INTO [users] AS target USING (VALUES (?, ?, ?)) AS source ([id], [name], [version]) ON target.[id] = source.[id]" +
+				" WHEN MATCHED THEN UPDATE SET [name] = source.[name], [version] = source.[version]" +
+				" WHEN NOT MATCHED THEN INSERT ([id], [name], [version]) VALUES (source.[id], source.[name], source.[version]);",
+			expectedKeyUpsert: "MERGE INTO [users] AS target USING (VALUES (?, ?, ?)) AS source ([id], [name], [version])" +
+				" ON target.[id] = source.[id] AND target.[name] = source.[name] AND target.[version] = source.[version]" +
+				" WHEN NOT MATCHED THEN INSERT ([id], [name], [version]) VALUES (source.[id], source.[name], source.[version]);",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			if res := tt.dialect.LimitOffset(); res != tt.expectedLimitOffset {
+				t.Errorf("Expected limit clause %q, got %q", tt.expectedLimitOffset, res)
+			}
+			if res := tt.dialect.LimitOffsetArgs(10, 20); !reflect.DeepEqual(res, tt.expectedArgs) {
+				t.Errorf("Expected limit args %v, got %v", tt.expectedArgs, res)
+			}
+			if res := tt.dialect.Quote(`public.user "x"`); res != tt.expectedQuote {
+				t.Errorf("Expected quoted identifier %s, got %s", tt.expectedQuote, res)
+			}
+			if res := tt.dialect.Upsert("users", columns, []string{"id"}); res != tt.expectedUpsert {
+				t.Errorf("Expected upsert\n%s\ngot\n%s", tt.expectedUpsert, res)
+			}
+			if res := tt.dialect.Upsert("users", columns, columns); res != tt.expectedKeyUpsert {
+				t.Errorf("Expected upsert of key columns\n%s\ngot\n%s", tt.expectedKeyUpsert, res)
+			}
+			if res := tt.dialect.Returning([]string{"id", "version"}); res != tt.expectedReturning {
+				t.Errorf("Expected returning clause %q, got %q", tt.expectedReturning, res)
+			}
+			if tt.dialect.SupportsReturning() != (tt.expectedReturning != "") {
+				t.Errorf("Expected returning support %v", tt.expectedReturning != "")
+			}
+		})
+	}
+}
+
+func TestDaoDialect(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	builder := newDepartmentDaoBuilder(db)
+	builder.Dialect = SQLServer
+	builder.SortColumns = map[string]string{"name": "name"}
+	builder.FilterColumns = map[string]string{"name": "name"}
+	builder.StmtCache = NewStmtCache(10)
+	departmentDao, err := builder.Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create DAO: %v", err)
+	}
+	dao := departmentDao.(*genericDao[*Department])
+
+	stmt, _, err := dao.listStmt(ctx, nil, []Sort{{Field: "name"}}, true)
+	if err != nil {
+		t.Fatalf("Failed to generate statement: %v", err)
+	}
+	query, args, _, err := parseNamedQuery(stmt.BaseStmt.Query).prepareArgs(stmt.getDialect().LimitOffsetArgs(10, 20), stmt.getDialect())
+	if err != nil {
+		t.Fatalf("Failed to prepare arguments: %v", err)
+	}
+	expected := "SELECT * FROM (SELECT id, name, version FROM departments) AS gosql_list ORDER BY name ASC OFFSET @p1 ROWS FETCH NEXT @p2 ROWS ONLY"
+	if query != expected || !reflect.DeepEqual(args, []any{20, 10}) {
+		t.Errorf("Expected %q with [20 10], got %q with %v", expected, query, args)
+	}
+
+	// Filtered pages are ordered even without a sort order, as OFFSET and FETCH require ORDER BY
+	stmt, _, err = dao.listStmt(ctx, Eq("name", "Math"), nil, true)
+	if err != nil {
+		t.Fatalf("Failed to generate statement: %v", err)
+	}
+	query = parseNamedQuery(stmt.BaseStmt.Query).rebind(stmt.getDialect())
+	expected = "SELECT * FROM (SELECT id, name, version FROM departments) AS gosql_list WHERE name = @p1 ORDER BY (SELECT NULL) OFFSET @p2 ROWS FETCH NEXT @p3 ROWS ONLY"
+	if query != expected {
+		t.Errorf("Expected %q, got %q", expected, query)
+	}
+
+	// Statements passed to the DAO are executed with its dialect and statement cache
+	passed := dao.queryStmt(&QueryStmt[*Department]{BaseStmt: BaseStmt{Query: "SELECT id, name, version FROM departments WHERE name = ?"}})
+	if passed.getDialect() != SQLServer || passed.getStmtCache() != builder.StmtCache {
+		t.Errorf("Expected passed statement to use the DAO's dialect and statement cache, got %s", passed.getDialect().Name())
+	}
+
+	cursorQuery := parseNamedQuery(dao.listAllCursorStmt.toQueryStmt(true, false).BaseStmt.Query).rebind(SQLServer)
+	expected = "SELECT * FROM (SELECT id, name, version FROM departments) AS gosql_cursor WHERE name > @p1 OR (name = @p2 AND id > @p3) ORDER BY name ASC, id ASC OFFSET @p4 ROWS FETCH NEXT @p5 ROWS ONLY"
+	if cursorQuery != expected {
+		t.Errorf("Expected %q, got %q", expected, cursorQuery)
+	}
+}
@@ -0,0 +1,81 @@
+package gosql
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Error is returned by the functions, statements and DAOs of gosql, describing the operation that failed
+// The cause is matched by errors.Is and errors.As, e.g. errors.Is(err, ErrVersionMismatch)
+type Error struct {
+	// Op is the operation that was called, e.g. "dao.Save" or "ExecStmt.Exec"
+	Op string
+	// Query is the query of the statement that failed, empty if the failure isn't caused by a gosql statement
+	Query string
+	// Entity is the type of the entity of a DAO operation, e.g. "*app.User"
+	Entity string
+	// ID is the ID of the entity the operation failed for, uuid.Nil if the failure isn't specific to an entity
+	ID uuid.UUID
+	// Err is the cause
+	Err error
+}
+
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString("gosql: ")
+	b.WriteString(e.Op)
+	if e.Entity != "" {
+		b.WriteString(" " + e.Entity)
+	}
+	if e.ID != uuid.Nil {
+		b.WriteString(" " + e.ID.String())
+	}
+	if e.Query != "" {
+		fmt.Fprintf(&b, ": query %q", e.Query)
+	}
+	fmt.Fprintf(&b, ": %v", e.Err)
+	return b.String()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// wrapError returns err as an *Error of the operation, nil if err is nil
+// The *Error of a nested operation is reused, keeping the query, entity and ID it failed for, so that the error
+// describes the operation that was called and the statement that failed. An empty op keeps the nested operation
+func wrapError(err error, op, query, entity string, id uuid.UUID) error {
+	if err == nil {
+		return nil
+	}
+	var res Error
+	if nested, ok := err.(*Error); ok {
+		res = *nested
+	} else {
+		res = Error{Err: err}
+	}
+	if op != "" {
+		res.Op = op
+	}
+	if res.Query == "" {
+		res.Query = query
+	}
+	if res.Entity == "" {
+		res.Entity = entity
+	}
+	if res.ID == uuid.Nil {
+		res.ID = id
+	}
+	return &res
+}
+
+// wrapTxError returns the error of an operation run within a transaction as an *Error of the transaction function
+// Errors of gosql operations are returned as is, as they describe the failure better than the transaction
+func wrapTxError(err error, op string) error {
+	if _, ok := err.(*Error); ok {
+		return err
+	}
+	return wrapError(err, op, "", "", uuid.Nil)
+}
@@ -0,0 +1,103 @@
+package gosql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+)
+
+func TestError(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+	if _, err := db.Exec("CREATE UNIQUE INDEX departments_name ON departments (name)"); err != nil {
+		t.Fatalf("Failed to create index: %v", err)
+	}
+	departmentDao := newDepartmentDao(t, db)
+	defer departmentDao.Close(ctx)
+
+	math := &Department{Name: "Math"}
+	if err := departmentDao.Save(ctx, math); err != nil {
+		t.Fatalf("Failed to save department: %v", err)
+	}
+
+	assertError := func(name string, err error, expected Error) {
+		t.Helper()
+		var gosqlErr *Error
+		if !errors.As(err, &gosqlErr) {
+			t.Fatalf("%s: expected *Error, got %v", name, err)
+		}
+		if !errors.Is(err, expected.Err) {
+			t.Errorf("%s: expected cause %v, got %v", name, expected.Err, gosqlErr.Err)
+		}
+		expected.Err = gosqlErr.Err
+		if *gosqlErr != expected {
+			t.Errorf("%s: expected %+v, got %+v", name, expected, *gosqlErr)
+		}
+	}
+
+	duplicate := &Department{Name: "Math"}
+	err := departmentDao.Save(ctx, duplicate)
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) {
+		t.Fatalf("Expected the driver error, got %v", err)
+	}
+	assertError("Save", err, Error{
+		Op:     "dao.Save",
+		Query:  "INSERT INTO departments (id, name, version) VALUES (?, ?, ?)",
+		Entity: "*gosql.Department",
+		ID:     duplicate.ID,
+		Err:    sqliteErr,
+	})
+
+	stale := &Department{GenericEntity: GenericEntity{ID: math.ID, Version: uuid.New()}, Name: "Mathematics"}
+	assertError("Save", departmentDao.Save(ctx, stale), Error{Op: "dao.Save", Entity: "*gosql.Department", ID: math.ID, Err: ErrVersionMismatch})
+
+	missingID := uuid.New()
+	_, err = departmentDao.FindById(ctx, missingID)
+	assertError("FindById", err, Error{Op: "dao.FindById", Entity: "*gosql.Department", ID: missingID, Err: ErrNotFound})
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("Expected sql.ErrNoRows, got %v", err)
+	}
+
+	_, err = departmentDao.ListBy(ctx, Eq("budget", 1))
+	assertError("ListBy", err, Error{Op: "dao.ListBy", Entity: "*gosql.Department", Err: ErrInvalidFilter})
+
+	for _, err := range departmentDao.StreamAll(ctx, Sort{Field: "budget"}) {
+		assertError("StreamAll", err, Error{Op: "dao.StreamAll", Entity: "*gosql.Department", Err: ErrInvalidSort})
+	}
+
+	// DAO errors within a transaction keep describing the DAO operation
+	err = ExecWithTx(ctx, db, RW, func(ctx context.Context, tx *sql.Tx) error {
+		return departmentDao.Save(ctx, stale)
+	})
+	assertError("ExecWithTx", err, Error{Op: "dao.Save", Entity: "*gosql.Department", ID: math.ID, Err: ErrVersionMismatch})
+
+	failure := errors.New("failure")
+	err = ExecWithTx(ctx, db, RW, func(ctx context.Context, tx *sql.Tx) error {
+		return failure
+	})
+	assertError("ExecWithTx", err, Error{Op: "ExecWithTx", Err: failure})
+
+	stmt := &ExecStmt{BaseStmt: BaseStmt{Query: "UPDATE courses SET name = ?"}}
+	err = ExecWithTx(ctx, db, RW, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := stmt.Exec(ctx, tx, "Algebra")
+		return err
+	})
+	var stmtErr *Error
+	if !errors.As(err, &stmtErr) || stmtErr.Op != "ExecStmt.Exec" || stmtErr.Query != stmt.Query || stmtErr.Err == nil {
+		t.Errorf("Expected the statement's error, got %+v", err)
+	}
+
+	err = (&PagingPolicy{Reject: true}).Apply(&Paging{PageNum: -1})
+	assertError("PagingPolicy.Apply", err, Error{Op: "PagingPolicy.Apply", Err: ErrInvalidPaging})
+
+	expected := "gosql: dao.Save *gosql.Department " + math.ID.String() + `: query "UPDATE x": gosql: version mismatch - entity was modified`
+	message := (&Error{Op: "dao.Save", Query: "UPDATE x", Entity: "*gosql.Department", ID: math.ID, Err: ErrVersionMismatch}).Error()
+	if message != expected {
+		t.Errorf("Expected message %q, got %q", expected, message)
+	}
+}
@@ -0,0 +1,100 @@
+package gosql
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// ErrEmptySlice is returned when an empty slice is bound to a placeholder, since no expansion of it works for both
+// IN and NOT IN, so callers have to handle empty slices themselves, e.g. by skipping the query
+var ErrEmptySlice = errors.New("gosql: empty slice argument")
+
+// expand expands slice arguments bound to placeholders into as many placeholders as the slice has elements,
+// so that a slice can be passed to an IN clause, e.g. "WHERE id IN (?)", and rebinds the placeholders to the dialect.
+// An empty slice fails with ErrEmptySlice. Byte slices and values implementing driver.Valuer
+// are passed as is. The returned flag reports whether any argument was expanded, which makes the query text
+// depend on the arguments
+func (q namedQuery) expand(args []any, dialect Dialect) (string, []any, bool, error) {
+	// arguments not matching ? placeholders, e.g. for driver-specific placeholders, are left to the driver
+	expandable := len(args) == len(q.positions)
+
+	var b strings.Builder
+	res := make([]any, 0, len(args))
+	expanded := false
+	last, n := 0, 0
+	for i, position := range q.positions {
+		b.WriteString(q.query[last:position])
+		last = position + 1
+
+		var elems []any
+		isSlice := false
+		if expandable {
+			elems, isSlice = sliceElems(args[i])
+		}
+		if !isSlice {
+			n++
+			b.WriteString(dialect.Placeholder(n))
+			if expandable {
+				res = append(res, args[i])
+			}
+			continue
+		}
+
+		expanded = true
+		if len(elems) == 0 {
+			return "", nil, false, ErrEmptySlice
+		}
+		for j, elem := range elems {
+			if j > 0 {
+				b.WriteString(", ")
+			}
+			n++
+			b.WriteString(dialect.Placeholder(n))
+			res = append(res, elem)
+		}
+	}
+	b.WriteString(q.query[last:])
+
+	if !expandable {
+		return b.String(), args, false, nil
+	}
+	return b.String(), res, expanded, nil
+}
+
+// rebind returns the query with placeholders rebound to the dialect, as it is prepared for arguments without slices
+func (q namedQuery) rebind(dialect Dialect) string {
+	// without arguments nothing is expanded, so there is no error
+	query, _, _, _ := q.expand(nil, dialect)
+	return query
+}
+
+// sliceElems returns the elements of the argument if it is a slice that should be expanded
+func sliceElems(arg any) ([]any, bool) {
+	if arg == nil {
+		return nil, false
+	}
+	if _, ok := arg.(driver.Valuer); ok {
+		return nil, false
+	}
+	v := reflect.ValueOf(arg)
+	if v.Kind() != reflect.Slice || v.Type().Elem().Kind() == reflect.Uint8 {
+		return nil, false
+	}
+	res := make([]any, v.Len())
+	for i := range res {
+		res[i] = v.Index(i).Interface()
+	}
+	return res, true
+}
+
+// prepareArgs binds the arguments to the query, expands slice arguments and rebinds placeholders to the dialect,
+// returning the query to prepare along with the positional arguments to execute it with
+func (q namedQuery) prepareArgs(args []any, dialect Dialect) (string, []any, bool, error) {
+	args, err := q.bind(args)
+	if err != nil {
+		return "", nil, false, err
+	}
+	return q.expand(args, dialect)
+}
@@ -0,0 +1,93 @@
+package gosql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNamedQueryExpand(t *testing.T) {
+	id1, id2 := uuid.New(), uuid.New()
+	tests := []struct {
+		name             string
+		query            string
+		args             []any
+		expectedQuery    string
+		expectedArgs     []any
+		expectedExpanded bool
+	}{
+		{
+			name:          "Scalar arguments",
+			query:         "SELECT * FROM t WHERE a = ? AND b = ?",
+			args:          []any{1, "x"},
+			expectedQuery: "SELECT * FROM t WHERE a = ? AND b = ?",
+			expectedArgs:  []any{1, "x"},
+		},
+		{
+			name:             "Slice arguments",
+			query:            "SELECT * FROM t WHERE a IN (?) AND b = ? AND c IN (?)",
+			args:             []any{[]int{1, 2, 3}, "x", []string{"y", "z"}},
+			expectedQuery:    "SELECT * FROM t WHERE a IN (?, ?, ?) AND b = ? AND c IN (?, ?)",
+			expectedArgs:     []any{1, 2, 3, "x", "y", "z"},
+			expectedExpanded: true,
+		},
+		{
+			name:          "UUIDs and byte slices aren't expanded",
+			query:         "SELECT * FROM t WHERE a = ? AND b = ?",
+			args:          []any{id1, []byte("x")},
+			expectedQuery: "SELECT * FROM t WHERE a = ? AND b = ?",
+			expectedArgs:  []any{id1, []byte("x")},
+		},
+		{
+			name:             "Slice of UUIDs",
+			query:            "DELETE FROM t WHERE id IN (?)",
+			args:             []any{[]uuid.UUID{id1, id2}},
+			expectedQuery:    "DELETE FROM t WHERE id IN (?, ?)",
+			expectedArgs:     []any{id1, id2},
+			expectedExpanded: true,
+		},
+		{
+			name:             "Named slice argument",
+			query:            "SELECT * FROM t WHERE a IN (:ids) AND b = ':ids'",
+			args:             []any{map[string]any{"ids": []int{1, 2}}},
+			expectedQuery:    "SELECT * FROM t WHERE a IN (?, ?) AND b = ':ids'",
+			expectedArgs:     []any{1, 2},
+			expectedExpanded: true,
+		},
+		{
+			name:          "Arguments not matching placeholders",
+			query:         "SELECT * FROM t WHERE a = $1",
+			args:          []any{[]int{1, 2}},
+			expectedQuery: "SELECT * FROM t WHERE a = $1",
+			expectedArgs:  []any{[]int{1, 2}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, expanded, err := parseNamedQuery(tt.query).prepareArgs(tt.args, SQLite)
+			if err != nil {
+				t.Fatalf("Failed to prepare arguments: %v", err)
+			}
+			if query != tt.expectedQuery {
+				t.Errorf("Expected query %q, got %q", tt.expectedQuery, query)
+			}
+			if !reflect.DeepEqual(args, tt.expectedArgs) {
+				t.Errorf("Expected args %v, got %v", tt.expectedArgs, args)
+			}
+			if expanded != tt.expectedExpanded {
+				t.Errorf("Expected expanded %v, got %v", tt.expectedExpanded, expanded)
+			}
+		})
+	}
+}
+
+func TestNamedQueryExpandEmptySlice(t *testing.T) {
+	for _, query := range []string{"SELECT * FROM t WHERE a IN (?)", "SELECT * FROM t WHERE a NOT IN (?)"} {
+		if _, _, _, err := parseNamedQuery(query).prepareArgs([]any{[]int{}}, SQLite); !errors.Is(err, ErrEmptySlice) {
+			t.Errorf("Expected ErrEmptySlice for %q, got %v", query, err)
+		}
+	}
+}
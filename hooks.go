@@ -0,0 +1,131 @@
+package gosql
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"sync"
+)
+
+// txHooks holds the lifecycle hooks registered within a transaction or a savepoint
+type txHooks struct {
+	mu            sync.Mutex
+	beforeCommit  []func(context.Context) error
+	afterCommit   []func(context.Context)
+	afterRollback []func(context.Context)
+}
+
+// BeforeCommit registers a hook that runs within the database transaction from the context right before the outermost commit
+// If the hook returns an error, the transaction is rolled back instead and the error is returned by the transaction owner
+// Returns ErrNoTransaction if there is no transaction in the context
+func BeforeCommit(ctx context.Context, db *sql.DB, hook func(context.Context) error) error {
+	hooks, err := hooksFromContext(ctx, db)
+	if err != nil {
+		return err
+	}
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.beforeCommit = append(hooks.beforeCommit, hook)
+	return nil
+}
+
+// AfterCommit registers a hook that runs after the database transaction from the context is committed by its owner
+// Returns ErrNoTransaction if there is no transaction in the context
+func AfterCommit(ctx context.Context, db *sql.DB, hook func(context.Context)) error {
+	hooks, err := hooksFromContext(ctx, db)
+	if err != nil {
+		return err
+	}
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.afterCommit = append(hooks.afterCommit, hook)
+	return nil
+}
+
+// AfterRollback registers a hook that runs after the database transaction from the context is rolled back,
+// or after the savepoint the hook was registered within is rolled back
+// Returns ErrNoTransaction if there is no transaction in the context
+func AfterRollback(ctx context.Context, db *sql.DB, hook func(context.Context)) error {
+	hooks, err := hooksFromContext(ctx, db)
+	if err != nil {
+		return err
+	}
+	hooks.mu.Lock()
+	defer hooks.mu.Unlock()
+	hooks.afterRollback = append(hooks.afterRollback, hook)
+	return nil
+}
+
+func hooksFromContext(ctx context.Context, db *sql.DB) (*txHooks, error) {
+	state, ok := txStateFromContext(ctx, db)
+	if !ok || state.hooks == nil {
+		slog.ErrorContext(ctx, "No transaction started by gosql in context to register hook")
+		return nil, ErrNoTransaction
+	}
+	return state.hooks, nil
+}
+
+// runBeforeCommit runs before-commit hooks, including the ones registered by other before-commit hooks, until one fails
+func (h *txHooks) runBeforeCommit(ctx context.Context) error {
+	for i := 0; ; i++ {
+		h.mu.Lock()
+		if i >= len(h.beforeCommit) {
+			h.mu.Unlock()
+			return nil
+		}
+		hook := h.beforeCommit[i]
+		h.mu.Unlock()
+
+		if err := hook(ctx); err != nil {
+			slog.ErrorContext(ctx, "Before commit hook failed", "error", err)
+			return err
+		}
+	}
+}
+
+func (h *txHooks) runAfterCommit(ctx context.Context) {
+	h.mu.Lock()
+	hooks := h.afterCommit
+	h.mu.Unlock()
+	slog.DebugContext(ctx, "Running after commit hooks", "count", len(hooks))
+	for _, hook := range hooks {
+		hook(ctx)
+	}
+}
+
+func (h *txHooks) runAfterRollback(ctx context.Context) {
+	h.mu.Lock()
+	hooks := h.afterRollback
+	h.mu.Unlock()
+	slog.DebugContext(ctx, "Running after rollback hooks", "count", len(hooks))
+	for _, hook := range hooks {
+		hook(ctx)
+	}
+}
+
+// merge moves the hooks registered within a released savepoint to the enclosing transaction or savepoint
+// The hooks are dropped if the enclosing transaction wasn't started by gosql and has no hooks
+func (h *txHooks) merge(child *txHooks) {
+	if h == nil {
+		return
+	}
+	child.mu.Lock()
+	defer child.mu.Unlock()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.beforeCommit = append(h.beforeCommit, child.beforeCommit...)
+	h.afterCommit = append(h.afterCommit, child.afterCommit...)
+	h.afterRollback = append(h.afterRollback, child.afterRollback...)
+}
+
+// mergeAfterRollback moves only the after-rollback hooks of a savepoint to the enclosing transaction or savepoint
+func (h *txHooks) mergeAfterRollback(child *txHooks) {
+	if h == nil {
+		return
+	}
+	child.mu.Lock()
+	defer child.mu.Unlock()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.afterRollback = append(h.afterRollback, child.afterRollback...)
+}
@@ -0,0 +1,114 @@
+package gosql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+)
+
+func TestTxHooks(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Create test table
+	_, err = db.Exec(`CREATE TABLE test (id INTEGER PRIMARY KEY, value TEXT)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	if err := AfterCommit(ctx, db, func(ctx context.Context) {}); !errors.Is(err, ErrNoTransaction) {
+		t.Errorf("Expected ErrNoTransaction outside of transaction, got %v", err)
+	}
+
+	// Hooks registered by nested callers fire once after the outermost commit
+	events := make([]string, 0)
+	err = ExecWithTx(ctx, db, RW, func(ctx context.Context, tx *sql.Tx) error {
+		return ExecWithTx(ctx, db, RW, func(ctx context.Context, tx *sql.Tx) error {
+			if err := BeforeCommit(ctx, db, func(ctx context.Context) error {
+				events = append(events, "before commit")
+				return nil
+			}); err != nil {
+				return err
+			}
+			if err := AfterCommit(ctx, db, func(ctx context.Context) {
+				events = append(events, "after commit")
+			}); err != nil {
+				return err
+			}
+			if err := AfterRollback(ctx, db, func(ctx context.Context) {
+				events = append(events, "after rollback")
+			}); err != nil {
+				return err
+			}
+
+			// Hooks of a rolled back savepoint are discarded, except for the after rollback ones
+			errNested := errors.New("nested failure")
+			err := ExecWithTx(WithPropagation(ctx, Nested), db, RW, func(ctx context.Context, tx *sql.Tx) error {
+				if err := AfterCommit(ctx, db, func(ctx context.Context) {
+					events = append(events, "discarded after commit")
+				}); err != nil {
+					return err
+				}
+				if err := AfterRollback(ctx, db, func(ctx context.Context) {
+					events = append(events, "savepoint rollback")
+				}); err != nil {
+					return err
+				}
+				return errNested
+			})
+			if !errors.Is(err, errNested) {
+				t.Errorf("Expected nested error, got %v", err)
+			}
+
+			events = append(events, "operation")
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatalf("Failed to execute transaction: %v", err)
+	}
+	expected := []string{"savepoint rollback", "operation", "before commit", "after commit"}
+	if len(events) != len(expected) {
+		t.Fatalf("Expected events %v, got %v", expected, events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("Expected events %v, got %v", expected, events)
+			break
+		}
+	}
+
+	// Failed before commit hook vetoes the commit
+	errVeto := errors.New("veto")
+	rolledBack := false
+	err = ExecWithTx(ctx, db, RW, func(ctx context.Context, tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO test (value) VALUES ('vetoed')"); err != nil {
+			return err
+		}
+		if err := AfterRollback(ctx, db, func(ctx context.Context) { rolledBack = true }); err != nil {
+			return err
+		}
+		if err := AfterCommit(ctx, db, func(ctx context.Context) { t.Error("Unexpected after commit hook call") }); err != nil {
+			return err
+		}
+		return BeforeCommit(ctx, db, func(ctx context.Context) error { return errVeto })
+	})
+	if !errors.Is(err, errVeto) {
+		t.Errorf("Expected veto error, got %v", err)
+	}
+	if !rolledBack {
+		t.Error("Expected after rollback hook to be called")
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM test").Scan(&count); err != nil {
+		t.Fatalf("Failed to count rows: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected vetoed insert to be rolled back, got %d rows", count)
+	}
+}
@@ -0,0 +1,299 @@
+package gosql
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidMapping is returned when a type can't be mapped to columns by its db tags
+var ErrInvalidMapping = errors.New("gosql: invalid mapping")
+
+// Mapper maps the fields of a struct tagged with db:"column" to SQL columns, deriving the functions required by DaoBuilder
+// Exported fields of embedded structs without a tag, like GenericEntity, are mapped as if they were fields of the struct.
+// A field of a struct or struct pointer type tagged with the ref option, e.g. db:"department_id,ref=id",
+// is mapped to the column of the referenced struct's field tagged with the ref name, allocating the struct when received.
+// Fields tagged with db:"-" and fields without a tag are not mapped, other options of the tags are ignored.
+type Mapper[T any] struct {
+	mapping *structMapping
+	elem    reflect.Type
+}
+
+// NewMapper returns the mapper of T, which must be a pointer to a struct
+// The mapping is resolved once per type and shared by all mappers of the type
+func NewMapper[T any]() (*Mapper[T], error) {
+	t := reflect.TypeFor[T]()
+	if t.Kind() != reflect.Pointer || t.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %s is not a pointer to a struct", ErrInvalidMapping, t)
+	}
+	mapping, err := mappingOf(t.Elem())
+	if err != nil {
+		return nil, err
+	}
+	return &Mapper[T]{mapping: mapping, elem: t.Elem()}, nil
+}
+
+// Columns returns the mapped columns in the order of the struct fields
+func (m *Mapper[T]) Columns() []string {
+	return append([]string(nil), m.mapping.columns...)
+}
+
+// NewReceiver returns a new instance of the entity
+func (m *Mapper[T]) NewReceiver() T {
+	return reflect.New(m.elem).Interface().(T)
+}
+
+// Receive returns the pointers to the fields of all columns in the order of Columns
+func (m *Mapper[T]) Receive(e T) []any {
+	v := reflect.ValueOf(e).Elem()
+	res := make([]any, len(m.mapping.fields))
+	for i := range m.mapping.fields {
+		res[i] = m.mapping.fields[i].pointer(v)
+	}
+	return res
+}
+
+// Args returns the values of all columns in the order of Columns
+func (m *Mapper[T]) Args(e T) []any {
+	v := reflect.ValueOf(e).Elem()
+	res := make([]any, len(m.mapping.fields))
+	for i := range m.mapping.fields {
+		res[i] = m.mapping.fields[i].value(v)
+	}
+	return res
+}
+
+// ReceiveFunc returns a function that returns the pointers to the fields of the columns in the given order,
+// for queries that don't select all columns in the order of Columns
+func (m *Mapper[T]) ReceiveFunc(columns ...string) (func(T) []any, error) {
+	fields, err := m.mapping.fieldsOf(columns)
+	if err != nil {
+		return nil, err
+	}
+	return func(e T) []any {
+		v := reflect.ValueOf(e).Elem()
+		res := make([]any, len(fields))
+		for i, field := range fields {
+			res[i] = field.pointer(v)
+		}
+		return res
+	}, nil
+}
+
+// ArgsFunc returns a function that returns the values of the columns in the given order,
+// e.g. for InsertArgs and UpdateArgs matching the placeholders of the statements
+func (m *Mapper[T]) ArgsFunc(columns ...string) (func(T) []any, error) {
+	fields, err := m.mapping.fieldsOf(columns)
+	if err != nil {
+		return nil, err
+	}
+	return func(e T) []any {
+		v := reflect.ValueOf(e).Elem()
+		res := make([]any, len(fields))
+		for i, field := range fields {
+			res[i] = field.value(v)
+		}
+		return res
+	}, nil
+}
+
+// structMapping holds the mapped fields of a struct type
+type structMapping struct {
+	columns  []string
+	fields   []mappedField
+	byColumn map[string]int
+}
+
+// mappedField is a struct field mapped to a column
+type mappedField struct {
+	column string
+	// index is the path to the field through embedded structs
+	index []int
+	// ref is the path to the mapped field within the referenced struct, nil unless the field is tagged with the ref option
+	ref []int
+}
+
+type mappingResult struct {
+	mapping *structMapping
+	err     error
+}
+
+// mappings caches the mapping results by struct type
+var mappings sync.Map
+
+// mappingOf returns the cached mapping of the struct type, resolving it on first use
+func mappingOf(t reflect.Type) (*structMapping, error) {
+	if res, ok := mappings.Load(t); ok {
+		return res.(mappingResult).mapping, res.(mappingResult).err
+	}
+	mapping, err := newStructMapping(t)
+	res, _ := mappings.LoadOrStore(t, mappingResult{mapping: mapping, err: err})
+	return res.(mappingResult).mapping, res.(mappingResult).err
+}
+
+func newStructMapping(t reflect.Type) (*structMapping, error) {
+	fields, err := collectFields(t, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	res := &structMapping{
+		columns:  make([]string, 0, len(fields)),
+		fields:   fields,
+		byColumn: make(map[string]int, len(fields)),
+	}
+	for i, field := range fields {
+		if _, ok := res.byColumn[field.column]; ok {
+			return nil, fmt.Errorf("%w: duplicate column %q in %s", ErrInvalidMapping, field.column, t)
+		}
+		res.byColumn[field.column] = i
+		res.columns = append(res.columns, field.column)
+	}
+	return res, nil
+}
+
+// collectFields collects the tagged fields of the struct type and its embedded structs
+// References are only resolved if withRefs is set, the fields of a referenced struct are collected without them,
+// so that self-referencing types don't recurse
+func collectFields(t reflect.Type, prefix []int, withRefs bool) ([]mappedField, error) {
+	res := make([]mappedField, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		index := append(append([]int(nil), prefix...), i)
+		name, opts, _ := strings.Cut(field.Tag.Get("db"), ",")
+		switch {
+		case name == "-":
+			continue
+		case name == "" && field.Anonymous:
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() != reflect.Struct {
+				continue
+			}
+			fields, err := collectFields(embedded, index, withRefs)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, fields...)
+		case name == "":
+			continue
+		default:
+			ref, hasRef := tagOption(opts, "ref")
+			if !hasRef {
+				res = append(res, mappedField{column: name, index: index})
+				continue
+			}
+			if !withRefs {
+				continue
+			}
+			refPath, err := refField(field, ref)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, mappedField{column: name, index: index, ref: refPath})
+		}
+	}
+	return res, nil
+}
+
+// tagOption returns the value of the option of a db tag, e.g. ref=id, among the comma-separated options
+func tagOption(opts, name string) (string, bool) {
+	for _, opt := range strings.Split(opts, ",") {
+		if value, ok := strings.CutPrefix(opt, name+"="); ok {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// refField returns the path to the field tagged with the ref name within the struct referenced by the field
+func refField(field reflect.StructField, ref string) ([]int, error) {
+	t := field.Type
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: field %s referencing %q is not a struct", ErrInvalidMapping, field.Name, ref)
+	}
+	fields, err := collectFields(t, nil, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fields {
+		if f.column == ref {
+			return f.index, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: field %s references unknown column %q of %s", ErrInvalidMapping, field.Name, ref, t)
+}
+
+// fieldsOf returns the fields of the columns in the given order
+func (m *structMapping) fieldsOf(columns []string) ([]mappedField, error) {
+	res := make([]mappedField, 0, len(columns))
+	for _, column := range columns {
+		i, ok := m.byColumn[column]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown column %q", ErrInvalidMapping, column)
+		}
+		res = append(res, m.fields[i])
+	}
+	return res, nil
+}
+
+// value returns the value of the field within the struct, nil if the path goes through a nil pointer
+func (f *mappedField) value(v reflect.Value) any {
+	v, ok := walk(v, f.index, false)
+	if !ok {
+		return nil
+	}
+	if f.ref != nil {
+		if v, ok = walk(v, f.ref, false); !ok {
+			return nil
+		}
+	}
+	return v.Interface()
+}
+
+// pointer returns the pointer to the field within the struct, allocating nil pointers along the path
+func (f *mappedField) pointer(v reflect.Value) any {
+	v, _ = walk(v, f.index, true)
+	if f.ref != nil {
+		v, _ = walk(v, f.ref, true)
+	}
+	return v.Addr().Interface()
+}
+
+// walk follows the path of field indexes, dereferencing pointers to structs and allocating nil ones if alloc is set
+// The last field is dereferenced only if it's followed by a reference path, which walk is called with separately
+func walk(v reflect.Value, path []int, alloc bool) (reflect.Value, bool) {
+	for _, i := range path {
+		if v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc {
+					return v, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+// lookup returns the lookup of the column values of the struct
+func (m *structMapping) lookup(v reflect.Value) func(string) (any, bool) {
+	return func(column string) (any, bool) {
+		i, ok := m.byColumn[column]
+		if !ok {
+			return nil, false
+		}
+		return m.fields[i].value(v), true
+	}
+}
@@ -0,0 +1,222 @@
+package gosql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+type mappedCourse struct {
+	*GenericEntity
+	Title    string        `db:"title"`
+	Credits  int           `db:"credits,omitempty"`
+	Student  *Student      `db:"student_id,list,ref=id"`
+	Mentor   *Department   `db:"mentor_name,ref=name"`
+	Parent   *mappedCourse `db:"parent_id,ref=id"`
+	Comment  string        `db:"-"`
+	Internal string
+	hidden   string
+}
+
+func TestMapper(t *testing.T) {
+	mapper, err := NewMapper[*mappedCourse]()
+	if err != nil {
+		t.Fatalf("Failed to create mapper: %v", err)
+	}
+
+	expectedColumns := []string{"id", "version", "title", "credits", "student_id", "mentor_name", "parent_id"}
+	if columns := mapper.Columns(); !reflect.DeepEqual(columns, expectedColumns) {
+		t.Fatalf("Expected columns %v, got %v", expectedColumns, columns)
+	}
+
+	t.Run("Args", func(t *testing.T) {
+		course := &mappedCourse{
+			GenericEntity: &GenericEntity{ID: uuid.New(), Version: uuid.New()},
+			Title:         "Algebra",
+			Credits:       5,
+			Student:       &Student{GenericEntity: GenericEntity{ID: uuid.New()}},
+		}
+		expected := []any{course.ID, course.Version, "Algebra", 5, course.Student.ID, nil, nil}
+		if args := mapper.Args(course); !reflect.DeepEqual(args, expected) {
+			t.Errorf("Expected args %v, got %v", expected, args)
+		}
+
+		// Nil embedded structs are mapped to NULL values
+		expected = []any{nil, nil, "", 0, nil, nil, nil}
+		if args := mapper.Args(&mappedCourse{}); !reflect.DeepEqual(args, expected) {
+			t.Errorf("Expected args %v, got %v", expected, args)
+		}
+	})
+
+	t.Run("Receive", func(t *testing.T) {
+		course := mapper.NewReceiver()
+		id, studentID, parentID := uuid.New(), uuid.New(), uuid.New()
+		values := []any{id, uuid.New(), "Geometry", 3, studentID, "Math", parentID}
+		for i, ptr := range mapper.Receive(course) {
+			reflect.ValueOf(ptr).Elem().Set(reflect.ValueOf(values[i]))
+		}
+
+		if course.GenericEntity == nil || course.ID != id {
+			t.Errorf("Expected ID %v to be received into the allocated embedded struct", id)
+		}
+		if course.Title != "Geometry" || course.Credits != 3 {
+			t.Errorf("Expected title and credits to be received, got %q and %d", course.Title, course.Credits)
+		}
+		if course.Student == nil || course.Student.ID != studentID {
+			t.Errorf("Expected student %v to be allocated and received", studentID)
+		}
+		if course.Mentor == nil || course.Mentor.Name != "Math" {
+			t.Errorf("Expected mentor to be allocated and received")
+		}
+		if course.Parent == nil || course.Parent.GenericEntity == nil || course.Parent.ID != parentID {
+			t.Errorf("Expected parent %v to be allocated and received", parentID)
+		}
+	})
+
+	t.Run("Functions of columns", func(t *testing.T) {
+		args, err := mapper.ArgsFunc("title", "id")
+		if err != nil {
+			t.Fatalf("Failed to create args function: %v", err)
+		}
+		course := &mappedCourse{GenericEntity: &GenericEntity{ID: uuid.New()}, Title: "Logic"}
+		if res := args(course); !reflect.DeepEqual(res, []any{"Logic", course.ID}) {
+			t.Errorf("Unexpected args %v", res)
+		}
+
+		receive, err := mapper.ReceiveFunc("credits")
+		if err != nil {
+			t.Fatalf("Failed to create receive function: %v", err)
+		}
+		if res := receive(course); len(res) != 1 || res[0] != &course.Credits {
+			t.Errorf("Expected the pointer to credits, got %v", res)
+		}
+
+		if _, err := mapper.ArgsFunc("comment"); !errors.Is(err, ErrInvalidMapping) {
+			t.Errorf("Expected ErrInvalidMapping for unmapped column, got %v", err)
+		}
+		if _, err := mapper.ReceiveFunc("unknown"); !errors.Is(err, ErrInvalidMapping) {
+			t.Errorf("Expected ErrInvalidMapping for unknown column, got %v", err)
+		}
+	})
+
+	t.Run("Mapping is cached per type", func(t *testing.T) {
+		another, err := NewMapper[*mappedCourse]()
+		if err != nil {
+			t.Fatalf("Failed to create mapper: %v", err)
+		}
+		if another.mapping != mapper.mapping {
+			t.Errorf("Expected the mapping to be shared")
+		}
+	})
+}
+
+func TestMapperInvalid(t *testing.T) {
+	type duplicate struct {
+		GenericEntity
+		Key string `db:"id"`
+	}
+	type notStruct struct {
+		Name string `db:"name_id,ref=id"`
+	}
+	type unknownRef struct {
+		Department *Department `db:"department_id,ref=code"`
+	}
+
+	if _, err := NewMapper[Department](); !errors.Is(err, ErrInvalidMapping) {
+		t.Errorf("Expected ErrInvalidMapping for non-pointer type, got %v", err)
+	}
+	if _, err := NewMapper[*duplicate](); !errors.Is(err, ErrInvalidMapping) {
+		t.Errorf("Expected ErrInvalidMapping for duplicate column, got %v", err)
+	}
+	if _, err := NewMapper[*notStruct](); !errors.Is(err, ErrInvalidMapping) {
+		t.Errorf("Expected ErrInvalidMapping for reference of non-struct, got %v", err)
+	}
+	if _, err := NewMapper[*unknownRef](); !errors.Is(err, ErrInvalidMapping) {
+		t.Errorf("Expected ErrInvalidMapping for reference of unknown column, got %v", err)
+	}
+}
+
+func TestMapperDao(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	mapper, err := NewMapper[*Student]()
+	if err != nil {
+		t.Fatalf("Failed to create mapper: %v", err)
+	}
+	receive, err := mapper.ReceiveFunc("id", "name", "department_id", "version")
+	if err != nil {
+		t.Fatalf("Failed to create receive function: %v", err)
+	}
+	insertArgs, err := mapper.ArgsFunc("id", "name", "department_id", "version")
+	if err != nil {
+		t.Fatalf("Failed to create insert args function: %v", err)
+	}
+	updateArgs, err := mapper.ArgsFunc("name", "department_id", "version", "id")
+	if err != nil {
+		t.Fatalf("Failed to create update args function: %v", err)
+	}
+	studentDao, err := DaoBuilder[*Student]{
+		DB:          db,
+		InsertStmt:  &DaoExecStmt{Query: `INSERT INTO students (id, name, department_id, version) VALUES (?, ?, ?, ?)`},
+		UpdateStmt:  &DaoExecStmt{Query: `UPDATE students SET name = ?, department_id = ?, version = ? WHERE id = ?`},
+		GetByIdStmt: &DaoQueryOneStmt[*Student]{Query: `SELECT id, name, department_id, version FROM students WHERE id = ?`},
+		ListAllStmt: &DaoQueryStmt[*Student]{Query: `SELECT id, name, department_id, version FROM students`},
+		ListAllPageStmt: &DaoQueryPageStmt[*Student]{
+			QueryStmt: &DaoQueryStmt[*Student]{Query: `SELECT id, name, department_id, version FROM students ORDER BY name LIMIT ? OFFSET ?`},
+			CountStmt: &DaoQueryValStmt[int]{Query: `SELECT COUNT(*) FROM students`},
+		},
+		DeleteByIdStmt: &DaoExecStmt{Query: `DELETE FROM students WHERE id = ?`},
+		NewReceiver:    mapper.NewReceiver,
+		Receive:        receive,
+		InsertArgs:     insertArgs,
+		UpdateArgs:     updateArgs,
+		SaveChildren:   func(ctx context.Context, tx *sql.Tx, s *Student) error { return nil },
+		LoadChildren:   func(ctx context.Context, tx *sql.Tx, s *Student) error { return nil },
+		DeleteChildren: func(ctx context.Context, tx *sql.Tx, s *Student) error { return nil },
+	}.Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create DAO: %v", err)
+	}
+	defer studentDao.Close(ctx)
+
+	departmentDao := newDepartmentDao(t, db)
+	defer departmentDao.Close(ctx)
+	dept := &Department{Name: "Physics"}
+	if err := departmentDao.Save(ctx, dept); err != nil {
+		t.Fatalf("Failed to save department: %v", err)
+	}
+
+	student := &Student{Name: "Alice", Department: dept}
+	if err := studentDao.Save(ctx, student); err != nil {
+		t.Fatalf("Failed to save student: %v", err)
+	}
+	student.Name = "Alice Smith"
+	if err := studentDao.Save(ctx, student); err != nil {
+		t.Fatalf("Failed to update student: %v", err)
+	}
+
+	found, err := studentDao.FindById(ctx, student.ID)
+	if err != nil {
+		t.Fatalf("Failed to find student: %v", err)
+	}
+	if found.Name != student.Name || found.Version != student.Version || found.Department == nil || found.Department.ID != dept.ID {
+		t.Errorf("Expected student %+v of department %s, got %+v", student, dept.ID, found)
+	}
+}
+
+func BenchmarkMapperReceive(b *testing.B) {
+	mapper, err := NewMapper[*Student]()
+	if err != nil {
+		b.Fatalf("Failed to create mapper: %v", err)
+	}
+	student := mapper.NewReceiver()
+	b.ReportAllocs()
+	for b.Loop() {
+		_ = mapper.Receive(student)
+	}
+}
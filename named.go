@@ -0,0 +1,289 @@
+package gosql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrInvalidNamedArgs is returned when the arguments of a statement with named parameters are missing, unused or of unsupported type
+var ErrInvalidNamedArgs = errors.New("gosql: invalid named arguments")
+
+// namedQuery is a query with named parameters rewritten to positional placeholders
+type namedQuery struct {
+	// query is the rewritten query with positional placeholders only
+	query string
+	// params holds the parameter names in the order of placeholders, empty for positional placeholders of the original query
+	params []string
+	// positions holds the offsets of the placeholders in the rewritten query
+	positions []int
+	// named reports whether the original query has any named parameters
+	named bool
+}
+
+// parseNamedQuery rewrites :name and @name parameters of the query to positional ? placeholders
+// Parameters within string literals, quoted identifiers and comments are left intact, as are :: casts and @@ variables
+func parseNamedQuery(query string) namedQuery {
+	var b strings.Builder
+	res := namedQuery{params: make([]string, 0)}
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end := skipQuoted(query, i, c)
+			b.WriteString(query[i:end])
+			i = end
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query) - i
+			} else {
+				end += 4
+			}
+			b.WriteString(query[i : i+end])
+			i += end
+		case (c == ':' || c == '@') && i+1 < len(query) && query[i+1] == c:
+			b.WriteString(query[i : i+2])
+			i += 2
+		case (c == ':' || c == '@') && i+1 < len(query) && isParamStart(query[i+1]):
+			end := i + 1
+			for end < len(query) && isParamPart(query[end]) {
+				end++
+			}
+			res.params = append(res.params, query[i+1:end])
+			res.positions = append(res.positions, b.Len())
+			res.named = true
+			b.WriteByte('?')
+			i = end
+		case c == '?':
+			res.params = append(res.params, "")
+			res.positions = append(res.positions, b.Len())
+			b.WriteByte(c)
+			i++
+		default:
+			b.WriteByte(c)
+			i++
+		}
+	}
+	res.query = b.String()
+	return res
+}
+
+// countPlaceholders returns the number of ? placeholders of a query without named parameters,
+// ok is false for queries with named parameters or without ? placeholders, e.g. with native placeholders like $1
+func countPlaceholders(query string) (int, bool) {
+	parsed := parseNamedQuery(query)
+	if parsed.named || len(parsed.params) == 0 {
+		return 0, false
+	}
+	return len(parsed.params), true
+}
+
+// versionedQuery derives the compare-and-swap variant of an update statement by adding the comparison of the version column
+// to its WHERE clause, which has to be the last clause of the statement. The previous version is bound to the placeholder
+// after the statement's placeholders, or to the previous_version parameter of statements with named parameters.
+// ok is false if the statement has no WHERE clause or no ? or named placeholders
+func versionedQuery(query string) (string, bool) {
+	where := lastKeyword(query, "WHERE")
+	if where < 0 {
+		return "", false
+	}
+	parsed := parseNamedQuery(query)
+	placeholder := "?"
+	if parsed.named {
+		placeholder = ":previous_version"
+	} else if len(parsed.params) == 0 {
+		return "", false
+	}
+	end := where + len("WHERE")
+	condition := strings.TrimSuffix(strings.TrimSpace(query[end:]), ";")
+	return query[:end] + " (" + strings.TrimSpace(condition) + ") AND version = " + placeholder, true
+}
+
+// lastKeyword returns the position of the last occurrence of the keyword outside of parentheses, quotes and comments, -1 if there is none
+func lastKeyword(query, keyword string) int {
+	res, depth := -1, 0
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			i = skipQuoted(query, i, c)
+		case strings.HasPrefix(query[i:], "--"):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				return res
+			}
+			i += end
+		case strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				return res
+			}
+			i += end + 4
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			depth--
+			i++
+		case isParamStart(c):
+			end := i
+			for end < len(query) && isParamPart(query[end]) {
+				end++
+			}
+			if depth == 0 && strings.EqualFold(query[i:end], keyword) && (i == 0 || !strings.ContainsRune(":@.", rune(query[i-1]))) {
+				res = i
+			}
+			i = end
+		default:
+			i++
+		}
+	}
+	return res
+}
+
+// skipQuoted returns the position right after the quoted literal starting at i, doubled quotes are treated as escaped
+func skipQuoted(query string, i int, quote byte) int {
+	for j := i + 1; j < len(query); j++ {
+		if query[j] != quote {
+			continue
+		}
+		if j+1 < len(query) && query[j+1] == quote {
+			j++
+			continue
+		}
+		return j + 1
+	}
+	return len(query)
+}
+
+func isParamStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isParamPart(c byte) bool {
+	return isParamStart(c) || (c >= '0' && c <= '9')
+}
+
+// bind converts the arguments of the query to positional ones
+// Named parameters are bound from sql.NamedArg arguments and from the first other argument if it is a map[string]any
+// or a struct with db tags mapped the way Mapper does, with sql.NamedArg arguments taking precedence.
+// The rest of the arguments are bound to positional placeholders in order.
+// Unused names of sql.NamedArg arguments and maps are rejected, while unused struct fields are allowed
+func (q namedQuery) bind(args []any) ([]any, error) {
+	if !q.named {
+		return args, nil
+	}
+
+	lookup, positional, unused, err := namedArgs(args)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]any, 0, len(q.params))
+	for _, param := range q.params {
+		if param == "" {
+			if len(positional) == 0 {
+				return nil, fmt.Errorf("%w: missing positional argument", ErrInvalidNamedArgs)
+			}
+			res = append(res, positional[0])
+			positional = positional[1:]
+			continue
+		}
+		value, ok := lookup(param)
+		if !ok {
+			return nil, fmt.Errorf("%w: missing argument for parameter %q", ErrInvalidNamedArgs, param)
+		}
+		delete(unused, param)
+		res = append(res, value)
+	}
+	if len(positional) > 0 {
+		return nil, fmt.Errorf("%w: %d unused positional arguments", ErrInvalidNamedArgs, len(positional))
+	}
+	for name := range unused {
+		return nil, fmt.Errorf("%w: unused argument %q", ErrInvalidNamedArgs, name)
+	}
+	return res, nil
+}
+
+// namedArgs returns the lookup of named arguments, the positional arguments and the names that must be used
+func namedArgs(args []any) (func(string) (any, bool), []any, map[string]struct{}, error) {
+	values := make(map[string]any)
+	positional := make([]any, 0, len(args))
+	for _, arg := range args {
+		if named, ok := arg.(sql.NamedArg); ok {
+			values[named.Name] = named.Value
+		} else {
+			positional = append(positional, arg)
+		}
+	}
+
+	first, firstUnused, err := firstArgLookup(positional)
+	if err != nil && len(values) == 0 {
+		return nil, nil, nil, err
+	}
+	if first == nil {
+		return mapLookup(values), positional, usedNames(values), nil
+	}
+	for name := range usedNames(values) {
+		firstUnused[name] = struct{}{}
+	}
+	lookup := func(name string) (any, bool) {
+		if value, ok := values[name]; ok {
+			return value, true
+		}
+		return first(name)
+	}
+	return lookup, positional[1:], firstUnused, nil
+}
+
+// firstArgLookup returns the lookup of the named arguments held by the first argument being a map or a struct,
+// along with the names that must be used, or a nil lookup and the reason if the first argument holds none
+func firstArgLookup(args []any) (func(string) (any, bool), map[string]struct{}, error) {
+	if len(args) == 0 {
+		return nil, nil, fmt.Errorf("%w: no named arguments", ErrInvalidNamedArgs)
+	}
+	if m, ok := args[0].(map[string]any); ok {
+		return mapLookup(m), usedNames(m), nil
+	}
+	v := reflect.ValueOf(args[0])
+	for v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("%w: unsupported type %T of named arguments", ErrInvalidNamedArgs, args[0])
+	}
+	mapping, err := mappingOf(v.Type())
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidNamedArgs, err)
+	}
+	// structs without db tags, e.g. time.Time, are values of positional placeholders
+	if len(mapping.columns) == 0 {
+		return nil, nil, fmt.Errorf("%w: %s has no db tags", ErrInvalidNamedArgs, v.Type())
+	}
+	return mapping.lookup(v), map[string]struct{}{}, nil
+}
+
+func mapLookup(m map[string]any) func(string) (any, bool) {
+	return func(name string) (any, bool) {
+		value, ok := m[name]
+		return value, ok
+	}
+}
+
+func usedNames(m map[string]any) map[string]struct{} {
+	res := make(map[string]struct{}, len(m))
+	for name := range m {
+		res[name] = struct{}{}
+	}
+	return res
+}
@@ -0,0 +1,241 @@
+package gosql
+
+import (
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestParseNamedQuery(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		expectedQuery  string
+		expectedParams []string
+		expectedNamed  bool
+	}{
+		{
+			name:           "Positional placeholders",
+			query:          "SELECT * FROM t WHERE a = ? AND b = ?",
+			expectedQuery:  "SELECT * FROM t WHERE a = ? AND b = ?",
+			expectedParams: []string{"", ""},
+		},
+		{
+			name:           "Colon and at parameters",
+			query:          "UPDATE t SET a = :a, b = @b_2 WHERE id = :id",
+			expectedQuery:  "UPDATE t SET a = ?, b = ? WHERE id = ?",
+			expectedParams: []string{"a", "b_2", "id"},
+			expectedNamed:  true,
+		},
+		{
+			name:           "Repeated parameter",
+			query:          "SELECT * FROM t WHERE a = :v OR b = :v",
+			expectedQuery:  "SELECT * FROM t WHERE a = ? OR b = ?",
+			expectedParams: []string{"v", "v"},
+			expectedNamed:  true,
+		},
+		{
+			name:           "Mixed with positional placeholders",
+			query:          "SELECT * FROM t WHERE a = :a LIMIT ? OFFSET ?",
+			expectedQuery:  "SELECT * FROM t WHERE a = ? LIMIT ? OFFSET ?",
+			expectedParams: []string{"a", "", ""},
+			expectedNamed:  true,
+		},
+		{
+			name:           "Literals, identifiers and comments are left intact",
+			query:          "SELECT ':a', \"@b\", `:c` -- :d ?\n/* @e ? */ FROM t WHERE x = 'it''s :f' AND y = :g",
+			expectedQuery:  "SELECT ':a', \"@b\", `:c` -- :d ?\n/* @e ? */ FROM t WHERE x = 'it''s :f' AND y = ?",
+			expectedParams: []string{"g"},
+			expectedNamed:  true,
+		},
+		{
+			name:           "Casts and variables are left intact",
+			query:          "SELECT a::text, @@version, b[1:2] FROM t WHERE c = :c::int",
+			expectedQuery:  "SELECT a::text, @@version, b[1:2] FROM t WHERE c = ?::int",
+			expectedParams: []string{"c"},
+			expectedNamed:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := parseNamedQuery(tt.query)
+			if res.query != tt.expectedQuery {
+				t.Errorf("Expected query %q, got %q", tt.expectedQuery, res.query)
+			}
+			if !reflect.DeepEqual(res.params, tt.expectedParams) {
+				t.Errorf("Expected params %v, got %v", tt.expectedParams, res.params)
+			}
+			if res.named != tt.expectedNamed {
+				t.Errorf("Expected named %v, got %v", tt.expectedNamed, res.named)
+			}
+		})
+	}
+}
+
+func TestVersionedQuery(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		expectedQuery string
+		expectedOk    bool
+	}{
+		{
+			name:          "Positional placeholders",
+			query:         "UPDATE t SET a = ?, version = ? WHERE id = ?",
+			expectedQuery: "UPDATE t SET a = ?, version = ? WHERE (id = ?) AND version = ?",
+			expectedOk:    true,
+		},
+		{
+			name:          "Named parameters",
+			query:         "update t set a = :a where id = :id or b = :b;",
+			expectedQuery: "update t set a = :a where (id = :id or b = :b) AND version = :previous_version",
+			expectedOk:    true,
+		},
+		{
+			name:          "WHERE in subquery and literal",
+			query:         "UPDATE t SET a = (SELECT x FROM s WHERE s.id = ?), b = 'WHERE' WHERE id = ?",
+			expectedQuery: "UPDATE t SET a = (SELECT x FROM s WHERE s.id = ?), b = 'WHERE' WHERE (id = ?) AND version = ?",
+			expectedOk:    true,
+		},
+		{name: "Without WHERE clause", query: "UPDATE t SET a = ?"},
+		{name: "Native placeholders", query: "UPDATE t SET a = $1 WHERE id = $2"},
+		{name: "WHERE only in subquery", query: "UPDATE t SET a = (SELECT x FROM s WHERE s.id = ?)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, ok := versionedQuery(tt.query)
+			if ok != tt.expectedOk || query != tt.expectedQuery {
+				t.Errorf("Expected %q, %v, got %q, %v", tt.expectedQuery, tt.expectedOk, query, ok)
+			}
+		})
+	}
+}
+
+func TestNamedQueryBind(t *testing.T) {
+	type person struct {
+		*GenericEntity
+		Name     string `db:"name"`
+		Age      int    `db:"age,omitempty"`
+		Nickname string
+		Ignored  string `db:"-"`
+	}
+	id := uuid.New()
+	p := &person{GenericEntity: &GenericEntity{ID: id}, Name: "John", Age: 42}
+	query := parseNamedQuery("SELECT * FROM t WHERE id = :id AND name = :name AND age > @age LIMIT ?")
+
+	tests := []struct {
+		name         string
+		query        namedQuery
+		args         []any
+		expectedArgs []any
+		expectedErr  error
+	}{
+		{
+			name:         "Positional query",
+			query:        parseNamedQuery("SELECT * FROM t WHERE a = ?"),
+			args:         []any{1},
+			expectedArgs: []any{1},
+		},
+		{
+			name:         "Map",
+			query:        query,
+			args:         []any{map[string]any{"id": id, "name": "John", "age": 42}, 10},
+			expectedArgs: []any{id, "John", 42, 10},
+		},
+		{
+			name:         "Struct with db tags",
+			query:        query,
+			args:         []any{p, 10},
+			expectedArgs: []any{id, "John", 42, 10},
+		},
+		{
+			name:         "Struct with referenced struct",
+			query:        parseNamedQuery("UPDATE students SET department_id = :department_id WHERE id = :id"),
+			args:         []any{&Student{GenericEntity: GenericEntity{ID: id}, Department: &Department{GenericEntity: GenericEntity{ID: id}}}},
+			expectedArgs: []any{id, id},
+		},
+		{
+			name:         "Named arguments",
+			query:        query,
+			args:         []any{sql.Named("age", 42), 10, sql.Named("name", "John"), sql.Named("id", id)},
+			expectedArgs: []any{id, "John", 42, 10},
+		},
+		{
+			name:         "Named arguments with struct",
+			query:        parseNamedQuery("UPDATE t SET name = :name WHERE id = :id AND version = :previous_version"),
+			args:         []any{p, sql.Named("previous_version", id), sql.Named("name", "Jane")},
+			expectedArgs: []any{"Jane", id, id},
+		},
+		{
+			name:         "Named arguments with positional timestamp",
+			query:        parseNamedQuery("SELECT * FROM t WHERE id = :id AND created_at > ?"),
+			args:         []any{sql.Named("id", id), time.Unix(0, 0)},
+			expectedArgs: []any{id, time.Unix(0, 0)},
+		},
+		{
+			name:        "Unused named argument with struct",
+			query:       parseNamedQuery("SELECT * FROM t WHERE id = :id"),
+			args:        []any{p, sql.Named("previous_version", id)},
+			expectedErr: ErrInvalidNamedArgs,
+		},
+		{
+			name:        "Missing name",
+			query:       query,
+			args:        []any{map[string]any{"id": id, "name": "John"}, 10},
+			expectedErr: ErrInvalidNamedArgs,
+		},
+		{
+			name:        "Unused name",
+			query:       query,
+			args:        []any{map[string]any{"id": id, "name": "John", "age": 42, "city": "Paris"}, 10},
+			expectedErr: ErrInvalidNamedArgs,
+		},
+		{
+			name:        "Missing positional argument",
+			query:       query,
+			args:        []any{p},
+			expectedErr: ErrInvalidNamedArgs,
+		},
+		{
+			name:        "Unused positional argument",
+			query:       query,
+			args:        []any{p, 10, 20},
+			expectedErr: ErrInvalidNamedArgs,
+		},
+		{
+			name:        "Untagged field",
+			query:       parseNamedQuery("SELECT * FROM t WHERE nickname = :Nickname"),
+			args:        []any{p},
+			expectedErr: ErrInvalidNamedArgs,
+		},
+		{
+			name:        "Unsupported type",
+			query:       query,
+			args:        []any{42, 10},
+			expectedErr: ErrInvalidNamedArgs,
+		},
+		{
+			name:        "No arguments",
+			query:       query,
+			expectedErr: ErrInvalidNamedArgs,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := tt.query.bind(tt.args)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+			if err == nil && !reflect.DeepEqual(args, tt.expectedArgs) {
+				t.Errorf("Expected args %v, got %v", tt.expectedArgs, args)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package gosql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// DefaultRetryPolicy is the retry policy used when no policy is provided
+var DefaultRetryPolicy = &RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   10 * time.Millisecond,
+	MaxDelay:    time.Second,
+	IsRetryable: IsRetryableError,
+}
+
+// RetryPolicy defines how transactions failed due to concurrent access are retried
+type RetryPolicy struct {
+	//MaxAttempts: Maximum number of attempts, including the first one
+	MaxAttempts int
+	//BaseDelay: Delay before the first retry, doubled for every next retry
+	BaseDelay time.Duration
+	//MaxDelay: Upper bound of the delay between retries
+	MaxDelay time.Duration
+	//IsRetryable: Function that tells whether an error is caused by concurrent access and can be retried.
+	//IsRetryableError is used if it is nil
+	IsRetryable func(error) bool
+}
+
+// sqlStateError is implemented by Postgres driver errors, e.g. *pq.Error and *pgconn.PgError
+type sqlStateError interface {
+	SQLState() string
+}
+
+var (
+	classifiersMu sync.RWMutex
+	// classifiers are the retry classifiers of drivers registered with RegisterRetryClassifier
+	classifiers []func(error) bool
+)
+
+// RegisterRetryClassifier registers a function that reports whether an error of a driver is worth retrying the whole
+// transaction for, which IsRetryableError consults for errors without a SQLSTATE, e.g. the sqlite subpackage's
+func RegisterRetryClassifier(classifier func(error) bool) {
+	classifiersMu.Lock()
+	defer classifiersMu.Unlock()
+	classifiers = append(classifiers, classifier)
+}
+
+// IsRetryableError reports whether the error is a serialization failure or deadlock reported by its SQLSTATE,
+// e.g. by Postgres, or an error a registered retry classifier deems worth retrying the whole transaction for
+func IsRetryableError(err error) bool {
+	var stateErr sqlStateError
+	if errors.As(err, &stateErr) {
+		// serialization_failure and deadlock_detected
+		return stateErr.SQLState() == "40001" || stateErr.SQLState() == "40P01"
+	}
+	classifiersMu.RLock()
+	defer classifiersMu.RUnlock()
+	for _, isRetryable := range classifiers {
+		if isRetryable(err) {
+			return true
+		}
+	}
+	return false
+}
+
+// ExecWithRetry executes an operation within a transaction like ExecWithTx and re-runs it if it fails with a retryable error
+// The operation is only retried when the call owns the outermost transaction, i.e. it doesn't join a transaction from the context
+func ExecWithRetry(ctx context.Context, db *sql.DB, opts *sql.TxOptions, policy *RetryPolicy, operation func(context.Context, *sql.Tx) error) error {
+	_, err := QueryWithRetry(ctx, db, opts, policy, func(ctx context.Context, tx *sql.Tx) (struct{}, error) {
+		return struct{}{}, operation(ctx, tx)
+	})
+	return err
+}
+
+// QueryWithRetry executes an operation that returns a result within a transaction like QueryWithTx and re-runs it if it fails with a retryable error
+// The operation is only retried when the call owns the outermost transaction, i.e. it doesn't join a transaction from the context
+func QueryWithRetry[T any](ctx context.Context, db *sql.DB, opts *sql.TxOptions, policy *RetryPolicy, operation func(context.Context, *sql.Tx) (T, error)) (T, error) {
+	if policy == nil {
+		policy = DefaultRetryPolicy
+	}
+	isRetryable := policy.IsRetryable
+	if isRetryable == nil {
+		isRetryable = IsRetryableError
+	}
+
+	_, inTx := TxFromContext(ctx, db)
+	if inTx && propagationFromContext(ctx) != RequiresNew {
+		slog.DebugContext(ctx, "Joining existing transaction, retries are left to the transaction owner")
+		return QueryWithTx(ctx, db, opts, operation)
+	}
+
+	for attempt := 1; ; attempt++ {
+		res, err := QueryWithTx(ctx, db, opts, operation)
+		if err == nil || attempt >= policy.MaxAttempts || !isRetryable(err) {
+			return res, err
+		}
+
+		delay := policy.backoff(attempt)
+		slog.WarnContext(ctx, "Retrying transaction", "attempt", attempt, "delay", delay, "error", err)
+		select {
+		case <-ctx.Done():
+			slog.ErrorContext(ctx, "Context done while waiting to retry transaction", "error", ctx.Err())
+			return res, errors.Join(err, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+// backoff calculates a jittered delay before the given retry attempt
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay << (attempt - 1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	// wait between a half and the full delay so that racing writers don't retry in lockstep
+	return delay/2 + rand.N(delay/2+1)
+}
@@ -0,0 +1,80 @@
+package gosql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidSort is returned when a sort order refers to a field that isn't sortable or has an unknown direction
+var ErrInvalidSort = errors.New("gosql: invalid sort")
+
+// MaxSortKeys is the maximum number of keys of a sort order, which bounds the number of statements generated for sort orders
+const MaxSortKeys = 5
+
+// SortDirection represents the direction of a sort key
+type SortDirection string
+
+const (
+	// Asc sorts in ascending order, the default for an empty direction
+	Asc SortDirection = "asc"
+	// Desc sorts in descending order
+	Desc SortDirection = "desc"
+)
+
+// Sort represents a key of a sort order requested for a listing
+// Field is an API field name, which is mapped to an SQL column by the DAO's sortable columns
+type Sort struct {
+	Field     string        `json:"field" yaml:"field"`
+	Direction SortDirection `json:"direction,omitempty" yaml:"direction,omitempty"`
+}
+
+// ParseSort parses a comma-separated list of fields, each optionally prefixed with - for descending
+// or + for ascending order, e.g. "name,-createdAt"
+func ParseSort(s string) []Sort {
+	res := make([]Sort, 0)
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		switch {
+		case field == "":
+			continue
+		case strings.HasPrefix(field, "-"):
+			res = append(res, Sort{Field: strings.TrimSpace(field[1:]), Direction: Desc})
+		case strings.HasPrefix(field, "+"):
+			res = append(res, Sort{Field: strings.TrimSpace(field[1:]), Direction: Asc})
+		default:
+			res = append(res, Sort{Field: field, Direction: Asc})
+		}
+	}
+	return res
+}
+
+// orderBy generates the content of an ORDER BY clause for the sort keys
+// Only the fields present in columns are accepted, so that the clause never contains user input,
+// each column at most once and up to MaxSortKeys keys
+func orderBy(columns map[string]string, sort []Sort) (string, error) {
+	if len(sort) > MaxSortKeys {
+		return "", fmt.Errorf("%w: %d sort keys exceed the maximum of %d", ErrInvalidSort, len(sort), MaxSortKeys)
+	}
+	keys := make([]string, 0, len(sort))
+	seen := make(map[string]bool, len(sort))
+	for _, s := range sort {
+		column, ok := columns[s.Field]
+		if !ok {
+			return "", fmt.Errorf("%w: field %q is not sortable", ErrInvalidSort, s.Field)
+		}
+		if seen[column] {
+			return "", fmt.Errorf("%w: field %q is sorted by more than once", ErrInvalidSort, s.Field)
+		}
+		seen[column] = true
+		switch {
+		case s.Direction == "" || strings.EqualFold(string(s.Direction), string(Asc)):
+			keys = append(keys, column+" ASC")
+		case strings.EqualFold(string(s.Direction), string(Desc)):
+			keys = append(keys, column+" DESC")
+		default:
+			return "", fmt.Errorf("%w: unknown direction %q of field %q", ErrInvalidSort, s.Direction, s.Field)
+		}
+	}
+	return strings.Join(keys, ", "), nil
+}
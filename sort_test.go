@@ -0,0 +1,68 @@
+package gosql
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestParseSort(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []Sort
+	}{
+		{name: "Empty", input: "", expected: []Sort{}},
+		{name: "Single field", input: "name", expected: []Sort{{Field: "name", Direction: Asc}}},
+		{
+			name:     "Multiple fields with directions",
+			input:    "-name, +id,version",
+			expected: []Sort{{Field: "name", Direction: Desc}, {Field: "id", Direction: Asc}, {Field: "version", Direction: Asc}},
+		},
+		{name: "Empty fields are skipped", input: ",name,,", expected: []Sort{{Field: "name", Direction: Asc}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if res := ParseSort(tt.input); !reflect.DeepEqual(res, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, res)
+			}
+		})
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	columns := map[string]string{"name": "d.name", "title": "d.name", "createdAt": "d.created_at"}
+	tests := []struct {
+		name        string
+		sort        []Sort
+		expected    string
+		expectedErr error
+	}{
+		{name: "Default direction", sort: []Sort{{Field: "name"}}, expected: "d.name ASC"},
+		{
+			name:     "Multiple keys",
+			sort:     []Sort{{Field: "createdAt", Direction: Desc}, {Field: "name", Direction: "ASC"}},
+			expected: "d.created_at DESC, d.name ASC",
+		},
+		{name: "Unknown field", sort: []Sort{{Field: "name; DROP TABLE departments"}}, expectedErr: ErrInvalidSort},
+		{name: "Column names aren't accepted as fields", sort: []Sort{{Field: "d.name"}}, expectedErr: ErrInvalidSort},
+		{name: "Unknown direction", sort: []Sort{{Field: "name", Direction: "sideways"}}, expectedErr: ErrInvalidSort},
+		{name: "Duplicate field", sort: []Sort{{Field: "name"}, {Field: "createdAt"}, {Field: "name", Direction: Desc}}, expectedErr: ErrInvalidSort},
+		{name: "Fields of the same column", sort: []Sort{{Field: "name"}, {Field: "title"}}, expectedErr: ErrInvalidSort},
+		{name: "Too many keys", sort: slices.Repeat([]Sort{{Field: "name"}}, MaxSortKeys+1), expectedErr: ErrInvalidSort},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := orderBy(columns, tt.sort)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+			if res != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, res)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package gosql
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidFilter is returned when a specification refers to a field that isn't filterable
+var ErrInvalidFilter = errors.New("gosql: invalid filter")
+
+// Spec is a composable filter condition over API fields, which a DAO translates into a WHERE clause
+// Fields are mapped to SQL columns by the DAO's filterable columns and values are always bound as arguments
+type Spec interface {
+	// toSQL generates the condition and its arguments, mapping fields to columns
+	toSQL(columns map[string]string) (string, []any, error)
+}
+
+type opSpec struct {
+	field string
+	op    string
+	value any
+}
+
+// Eq matches rows where the field equals the value, use IsNull to match NULL values
+func Eq(field string, value any) Spec {
+	return opSpec{field: field, op: "=", value: value}
+}
+
+// Like matches rows where the field matches the SQL LIKE pattern
+func Like(field string, pattern string) Spec {
+	return opSpec{field: field, op: "LIKE", value: pattern}
+}
+
+func (s opSpec) toSQL(columns map[string]string) (string, []any, error) {
+	column, err := filterColumn(columns, s.field)
+	if err != nil {
+		return "", nil, err
+	}
+	return column + " " + s.op + " ?", []any{s.value}, nil
+}
+
+type inSpec struct {
+	field  string
+	values []any
+}
+
+// In matches rows where the field equals any of the values, an empty list of values matches no rows
+func In(field string, values ...any) Spec {
+	return inSpec{field: field, values: values}
+}
+
+func (s inSpec) toSQL(columns map[string]string) (string, []any, error) {
+	column, err := filterColumn(columns, s.field)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(s.values) == 0 {
+		return "1 = 0", nil, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(s.values)), ", ")
+	return column + " IN (" + placeholders + ")", s.values, nil
+}
+
+type betweenSpec struct {
+	field    string
+	from, to any
+}
+
+// Between matches rows where the field is within the inclusive range
+func Between(field string, from, to any) Spec {
+	return betweenSpec{field: field, from: from, to: to}
+}
+
+func (s betweenSpec) toSQL(columns map[string]string) (string, []any, error) {
+	column, err := filterColumn(columns, s.field)
+	if err != nil {
+		return "", nil, err
+	}
+	return column + " BETWEEN ? AND ?", []any{s.from, s.to}, nil
+}
+
+type nullSpec struct {
+	field string
+}
+
+// IsNull matches rows where the field is NULL
+func IsNull(field string) Spec {
+	return nullSpec{field: field}
+}
+
+func (s nullSpec) toSQL(columns map[string]string) (string, []any, error) {
+	column, err := filterColumn(columns, s.field)
+	if err != nil {
+		return "", nil, err
+	}
+	return column + " IS NULL", nil, nil
+}
+
+type junctionSpec struct {
+	op    string
+	specs []Spec
+}
+
+// And matches rows that match all of the specifications, no specifications match all rows
+func And(specs ...Spec) Spec {
+	return junctionSpec{op: "AND", specs: specs}
+}
+
+// Or matches rows that match any of the specifications, no specifications match no rows
+func Or(specs ...Spec) Spec {
+	return junctionSpec{op: "OR", specs: specs}
+}
+
+func (s junctionSpec) toSQL(columns map[string]string) (string, []any, error) {
+	if len(s.specs) == 0 {
+		if s.op == "AND" {
+			return "1 = 1", nil, nil
+		}
+		return "1 = 0", nil, nil
+	}
+	conditions := make([]string, 0, len(s.specs))
+	args := make([]any, 0)
+	for _, spec := range s.specs {
+		condition, specArgs, err := toSQL(spec, columns)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, specArgs...)
+	}
+	return "(" + strings.Join(conditions, " "+s.op+" ") + ")", args, nil
+}
+
+type notSpec struct {
+	spec Spec
+}
+
+// Not matches rows that don't match the specification
+func Not(spec Spec) Spec {
+	return notSpec{spec: spec}
+}
+
+func (s notSpec) toSQL(columns map[string]string) (string, []any, error) {
+	condition, args, err := toSQL(s.spec, columns)
+	if err != nil {
+		return "", nil, err
+	}
+	return "NOT (" + condition + ")", args, nil
+}
+
+// toSQL generates the condition of a specification, rejecting nil specifications within a composition
+func toSQL(spec Spec, columns map[string]string) (string, []any, error) {
+	if spec == nil {
+		return "", nil, fmt.Errorf("%w: nil specification", ErrInvalidFilter)
+	}
+	return spec.toSQL(columns)
+}
+
+// matchesAll reports whether the specification is nil or an And of no restricting specifications, so it matches all rows
+func matchesAll(spec Spec) bool {
+	if spec == nil {
+		return true
+	}
+	junction, ok := spec.(junctionSpec)
+	if !ok || junction.op != "AND" {
+		return false
+	}
+	for _, s := range junction.specs {
+		if !matchesAll(s) {
+			return false
+		}
+	}
+	return true
+}
+
+func filterColumn(columns map[string]string, field string) (string, error) {
+	column, ok := columns[field]
+	if !ok {
+		return "", fmt.Errorf("%w: field %q is not filterable", ErrInvalidFilter, field)
+	}
+	return column, nil
+}
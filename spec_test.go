@@ -0,0 +1,53 @@
+package gosql
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSpecToSQL(t *testing.T) {
+	columns := map[string]string{"name": "d.name", "age": "d.age", "deletedAt": "d.deleted_at"}
+	tests := []struct {
+		name         string
+		spec         Spec
+		expected     string
+		expectedArgs []any
+		expectedErr  error
+	}{
+		{name: "Eq", spec: Eq("name", "John"), expected: "d.name = ?", expectedArgs: []any{"John"}},
+		{name: "Like", spec: Like("name", "J%"), expected: "d.name LIKE ?", expectedArgs: []any{"J%"}},
+		{name: "In", spec: In("age", 1, 2, 3), expected: "d.age IN (?, ?, ?)", expectedArgs: []any{1, 2, 3}},
+		{name: "Empty In", spec: In("age"), expected: "1 = 0"},
+		{name: "Between", spec: Between("age", 18, 65), expected: "d.age BETWEEN ? AND ?", expectedArgs: []any{18, 65}},
+		{name: "IsNull", spec: IsNull("deletedAt"), expected: "d.deleted_at IS NULL"},
+		{
+			name:         "Composition",
+			spec:         And(Or(Eq("name", "John"), Like("name", "A%")), Not(IsNull("deletedAt")), Between("age", 18, 65)),
+			expected:     "((d.name = ? OR d.name LIKE ?) AND NOT (d.deleted_at IS NULL) AND d.age BETWEEN ? AND ?)",
+			expectedArgs: []any{"John", "A%", 18, 65},
+		},
+		{name: "Empty And", spec: And(), expected: "1 = 1"},
+		{name: "Empty Or", spec: Or(), expected: "1 = 0"},
+		{name: "Unknown field", spec: Eq("d.name", "John"), expectedErr: ErrInvalidFilter},
+		{name: "Unknown nested field", spec: Not(Or(Eq("name", "John"), IsNull("1=1) OR (1"))), expectedErr: ErrInvalidFilter},
+		{name: "Nil nested specification", spec: And(Eq("name", "John"), nil), expectedErr: ErrInvalidFilter},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, args, err := tt.spec.toSQL(columns)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+			if res != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, res)
+			}
+			if len(args) != 0 || len(tt.expectedArgs) != 0 {
+				if !reflect.DeepEqual(args, tt.expectedArgs) {
+					t.Errorf("Expected args %v, got %v", tt.expectedArgs, args)
+				}
+			}
+		})
+	}
+}
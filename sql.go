@@ -3,10 +3,74 @@ package gosql
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"iter"
 	"log/slog"
+	"slices"
+
+	"github.com/google/uuid"
 )
 
-type txKey struct{}
+type txKey struct {
+	db *sql.DB
+}
+
+type legacyTxKey struct{}
+
+type txDBKey struct {
+	tx *sql.Tx
+}
+
+type propagationKey struct{}
+
+// txState holds the transaction of a database stored in the context
+type txState struct {
+	tx *sql.Tx
+	// opts are the options the transaction was started with, nil if it wasn't started by gosql
+	opts *sql.TxOptions
+	// hooks are the lifecycle hooks of the transaction or the current savepoint, nil if it wasn't started by gosql
+	hooks *txHooks
+	// savepoints is the number of nested savepoints
+	savepoints int
+}
+
+// Propagation defines how ExecWithTx and QueryWithTx behave when a transaction already exists in the context
+type Propagation int
+
+const (
+	// Required joins the transaction from the context or begins a new one if there is none. This is the default
+	Required Propagation = iota
+	// Nested runs the operation within a savepoint of the transaction from the context, so that a failed operation
+	// is rolled back without discarding the outer transaction. Begins a new transaction if there is none
+	Nested
+	// RequiresNew always begins a new transaction that is committed or rolled back independently of the transaction
+	// from the context
+	RequiresNew
+	// Mandatory joins the transaction from the context and fails with ErrNoTransaction if there is none
+	Mandatory
+	// Never fails with ErrTransactionExists if there is a transaction in the context. Otherwise the operation runs
+	// without a transaction, see Supports
+	Never
+	// Supports joins the transaction from the context if there is one. Otherwise the operation runs without a transaction:
+	// it is passed a nil *sql.Tx and every gosql statement it executes is committed on its own, without holding locks
+	// between statements. Calls made by the operation that require a transaction begin their own
+	Supports
+)
+
+var (
+	// ErrNoTransaction is returned when Mandatory propagation is requested or a transaction hook is registered
+	// without a transaction in the context
+	ErrNoTransaction = errors.New("gosql: no transaction in context")
+	// ErrTransactionExists is returned when Never propagation is requested with a transaction in the context
+	ErrTransactionExists = errors.New("gosql: transaction exists in context for never propagation")
+	// ErrReadOnlyTransaction is returned when a read-write operation tries to join a read-only transaction
+	ErrReadOnlyTransaction = errors.New("gosql: read-write operation cannot join read-only transaction")
+	// ErrIsolationLevelMismatch is returned when an operation tries to join a transaction with a weaker isolation level than requested
+	ErrIsolationLevelMismatch = errors.New("gosql: transaction isolation level is weaker than requested")
+	// ErrInvalidPaging is returned when pagination parameters are rejected by the paging policy
+	ErrInvalidPaging = errors.New("gosql: invalid paging")
+)
 
 // RO represents read-only transaction options
 var (
@@ -14,28 +78,143 @@ var (
 	// RW represents read-write transaction options
 	RW = &sql.TxOptions{ReadOnly: false}
 
-	// TxKey is the context key used to store and retrieve transaction objects
-	TxKey = txKey{}
+	// TxKey is the context key of a *sql.Tx that is joined by operations on any database without a transaction of its own
+	// in the context, as it doesn't tell which database the transaction belongs to
+	//
+	// Deprecated: use WithTx, which keeps the transaction of each database separately
+	TxKey = legacyTxKey{}
 )
 
+// WithTx returns a copy of the context carrying a transaction of the database, managed outside of gosql,
+// that ExecWithTx, QueryWithTx and Dao methods working with the same database will join
+func WithTx(ctx context.Context, db *sql.DB, tx *sql.Tx) context.Context {
+	ctx = context.WithValue(ctx, txDBKey{tx: tx}, db)
+	return context.WithValue(ctx, txKey{db: db}, &txState{tx: tx})
+}
+
+// TxFromContext returns the current transaction of the database from the context
+// Transactions of other databases stored in the same context are ignored
+func TxFromContext(ctx context.Context, db *sql.DB) (*sql.Tx, bool) {
+	state, ok := txStateFromContext(ctx, db)
+	if !ok {
+		return nil, false
+	}
+	return state.tx, true
+}
+
+func txStateFromContext(ctx context.Context, db *sql.DB) (*txState, bool) {
+	if state, ok := ctx.Value(txKey{db: db}).(*txState); ok {
+		return state, true
+	}
+	if tx, ok := ctx.Value(TxKey).(*sql.Tx); ok && tx != nil {
+		return &txState{tx: tx}, true
+	}
+	return nil, false
+}
+
+// dbFromContext returns the database a transaction from the context belongs to
+// For a nil transaction it returns the database of the operation running without a transaction, see Supports
+func dbFromContext(ctx context.Context, tx *sql.Tx) (*sql.DB, bool) {
+	db, ok := ctx.Value(txDBKey{tx: tx}).(*sql.DB)
+	return db, ok
+}
+
+// WithPropagation returns a copy of the context that makes the next ExecWithTx or QueryWithTx call,
+// including the ones made by Dao methods, use the given propagation
+func WithPropagation(ctx context.Context, propagation Propagation) context.Context {
+	return context.WithValue(ctx, propagationKey{}, propagation)
+}
+
+func propagationFromContext(ctx context.Context) Propagation {
+	if propagation, ok := ctx.Value(propagationKey{}).(Propagation); ok {
+		return propagation
+	}
+	return Required
+}
+
 // Page represents a paginated result set of items
+// TotalPages and TotalItems are -1 if the page was queried with Paging.SkipCount
 type Page[T any] struct {
-	Items      []T `json:"items" yaml:"items"`
-	TotalPages int `json:"totalPages" yaml:"totalPages"`
+	Items      []T  `json:"items" yaml:"items"`
+	TotalPages int  `json:"totalPages" yaml:"totalPages"`
+	TotalItems int  `json:"totalItems" yaml:"totalItems"`
+	PageNum    int  `json:"pageNum" yaml:"pageNum"`
+	PageSize   int  `json:"pageSize" yaml:"pageSize"`
+	HasNext    bool `json:"hasNext" yaml:"hasNext"`
+	HasPrev    bool `json:"hasPrev" yaml:"hasPrev"`
 }
 
 // Paging represents pagination parameters
+// SkipCount skips the count query when the totals aren't needed
 type Paging struct {
-	PageNum  int `json:"pageNum" yaml:"pageNum"`
-	PageSize int `json:"pageSize" yaml:"pageSize"`
+	PageNum   int  `json:"pageNum" yaml:"pageNum"`
+	PageSize  int  `json:"pageSize" yaml:"pageSize"`
+	SkipCount bool `json:"skipCount,omitempty" yaml:"skipCount,omitempty"`
 }
 
-// Normalize ensures that pagination parameters have valid values
-func (p *Paging) Normalize() {
-	if p.PageNum <= 0 || p.PageSize <= 0 {
+// PagingPolicy defines how pagination parameters are validated
+// Zero PageNum and PageSize are treated as unset and replaced with 1 and DefaultPageSize respectively
+type PagingPolicy struct {
+	//DefaultPageSize: Page size used when none is requested, 20 if not positive
+	DefaultPageSize int
+	//MaxPageSize: Maximum allowed page size, unlimited if not positive
+	MaxPageSize int
+	//Reject: Whether invalid parameters are rejected with ErrInvalidPaging instead of being clamped to valid values
+	Reject bool
+}
+
+// DefaultPagingPolicy is the paging policy used by QueryPage and DAOs without their own policy
+var DefaultPagingPolicy = &PagingPolicy{DefaultPageSize: 20, MaxPageSize: 1000}
+
+// Apply validates the pagination parameters according to the policy, replacing unset ones with defaults
+// Invalid parameters are clamped to the nearest valid values, or rejected with ErrInvalidPaging if the policy says so
+func (policy *PagingPolicy) Apply(p *Paging) error {
+	return wrapError(policy.apply(p), "PagingPolicy.Apply", "", "", uuid.Nil)
+}
+
+func (policy *PagingPolicy) apply(p *Paging) error {
+	defaultPageSize := policy.DefaultPageSize
+	if defaultPageSize <= 0 {
+		defaultPageSize = 20
+	}
+	if policy.MaxPageSize > 0 && defaultPageSize > policy.MaxPageSize {
+		defaultPageSize = policy.MaxPageSize
+	}
+
+	switch {
+	case p.PageNum == 0:
 		p.PageNum = 1
-		p.PageSize = 20
+	case p.PageNum < 0 && policy.Reject:
+		return fmt.Errorf("%w: page number %d is not positive", ErrInvalidPaging, p.PageNum)
+	case p.PageNum < 0:
+		p.PageNum = 1
+	}
+
+	switch {
+	case p.PageSize == 0:
+		p.PageSize = defaultPageSize
+	case p.PageSize < 0 && policy.Reject:
+		return fmt.Errorf("%w: page size %d is not positive", ErrInvalidPaging, p.PageSize)
+	case p.PageSize < 0:
+		p.PageSize = defaultPageSize
+	case policy.MaxPageSize > 0 && p.PageSize > policy.MaxPageSize && policy.Reject:
+		return fmt.Errorf("%w: page size %d exceeds maximum of %d", ErrInvalidPaging, p.PageSize, policy.MaxPageSize)
+	case policy.MaxPageSize > 0 && p.PageSize > policy.MaxPageSize:
+		p.PageSize = policy.MaxPageSize
 	}
+	return nil
+}
+
+// clamp applies the policy without rejecting invalid parameters
+func (policy *PagingPolicy) clamp(p *Paging) {
+	clamping := *policy
+	clamping.Reject = false
+	_ = clamping.apply(p)
+}
+
+// Normalize ensures that pagination parameters have valid values, clamping them according to DefaultPagingPolicy
+func (p *Paging) Normalize() {
+	DefaultPagingPolicy.clamp(p)
 }
 
 // GetOffset calculates the offset for SQL queries based on page number and size
@@ -57,27 +236,36 @@ func (p Paging) GetTotalPages(totalRows int) int {
 	return result
 }
 
-// Exec executes a SQL statement with the given arguments
-func Exec(ctx context.Context, tx *sql.Tx, stmt *sql.Stmt, args ...any) error {
+// bind returns the statement bound to the transaction, or the statement itself if tx is nil, see Supports
+func bind(ctx context.Context, tx *sql.Tx, stmt *sql.Stmt) *sql.Stmt {
+	if tx == nil {
+		return stmt
+	}
+	return tx.StmtContext(ctx, stmt)
+}
+
+// Exec executes a SQL statement with the given arguments and returns its result, e.g. the number of affected rows
+func Exec(ctx context.Context, tx *sql.Tx, stmt *sql.Stmt, args ...any) (sql.Result, error) {
 	slog.DebugContext(ctx, "Executing SQL statement", "stmt", stmt, "args_count", len(args))
-	_, err := tx.StmtContext(ctx, stmt).ExecContext(ctx, args...)
+	res, err := bind(ctx, tx, stmt).ExecContext(ctx, args...)
 	if err != nil {
 		slog.ErrorContext(ctx, "Failed to execute SQL statement", "error", err)
+		return nil, wrapError(TranslateError(err), "Exec", "", "", uuid.Nil)
 	}
-	return err
+	return res, nil
 }
 
 // Query executes a SQL query and returns a slice of results
 func Query[T any](ctx context.Context, tx *sql.Tx, stmt *sql.Stmt, newReceiver func() T, dstFields func(T) []any, args ...any) ([]T, error) {
 	slog.DebugContext(ctx, "Executing SQL query", "stmt", stmt, "args_count", len(args))
-	rows, err := tx.StmtContext(ctx, stmt).QueryContext(ctx, args...)
+	rows, err := bind(ctx, tx, stmt).QueryContext(ctx, args...)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			slog.DebugContext(ctx, "No rows returned from query")
 			return nil, nil
 		}
 		slog.ErrorContext(ctx, "Failed to execute SQL query", "error", err)
-		return nil, err
+		return nil, wrapError(TranslateError(err), "Query", "", "", uuid.Nil)
 	}
 
 	defer rows.Close()
@@ -87,7 +275,7 @@ func Query[T any](ctx context.Context, tx *sql.Tx, stmt *sql.Stmt, newReceiver f
 		t := newReceiver()
 		if err := rows.Scan(dstFields(t)...); err != nil {
 			slog.ErrorContext(ctx, "Failed to scan row", "error", err)
-			return nil, err
+			return nil, wrapError(TranslateError(err), "Query", "", "", uuid.Nil)
 		}
 		res = append(res, t)
 	}
@@ -95,41 +283,135 @@ func Query[T any](ctx context.Context, tx *sql.Tx, stmt *sql.Stmt, newReceiver f
 	return res, nil
 }
 
+// Stream executes a SQL query and returns a sequence of results that are scanned lazily while the caller ranges over it
+// The rows stay open until the iteration ends and are closed when the caller breaks out of the loop early
+// Iteration stops after the first error is yielded
+func Stream[T any](ctx context.Context, tx *sql.Tx, stmt *sql.Stmt, newReceiver func() T, dstFields func(T) []any, args ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		slog.DebugContext(ctx, "Executing SQL query for streaming", "stmt", stmt, "args_count", len(args))
+		rows, err := bind(ctx, tx, stmt).QueryContext(ctx, args...)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to execute SQL query", "error", err)
+			yield(Nil[T](), wrapError(TranslateError(err), "Stream", "", "", uuid.Nil))
+			return
+		}
+		defer rows.Close()
+
+		count := 0
+		for rows.Next() {
+			t := newReceiver()
+			if err := rows.Scan(dstFields(t)...); err != nil {
+				slog.ErrorContext(ctx, "Failed to scan row", "error", err)
+				yield(Nil[T](), wrapError(TranslateError(err), "Stream", "", "", uuid.Nil))
+				return
+			}
+			count++
+			if !yield(t, nil) {
+				slog.DebugContext(ctx, "Streaming stopped by caller", "count", count)
+				return
+			}
+		}
+		if err := rows.Err(); err != nil {
+			slog.ErrorContext(ctx, "Failed to iterate over rows", "error", err)
+			yield(Nil[T](), wrapError(TranslateError(err), "Stream", "", "", uuid.Nil))
+			return
+		}
+		slog.DebugContext(ctx, "Streaming query completed", "count", count)
+	}
+}
+
 // QueryOne executes a SQL query and returns a single result
 func QueryOne[T any](ctx context.Context, tx *sql.Tx, stmt *sql.Stmt, newReceiver func() T, dstFields func(T) []any, args ...any) (T, error) {
 	slog.DebugContext(ctx, "Executing SQL query for single result", "stmt", stmt, "args_count", len(args))
-	row := tx.StmtContext(ctx, stmt).QueryRowContext(ctx, args...)
+	row := bind(ctx, tx, stmt).QueryRowContext(ctx, args...)
 
 	t := newReceiver()
 	if err := row.Scan(dstFields(t)...); err != nil {
 		if err == sql.ErrNoRows {
 			slog.DebugContext(ctx, "No row found for query")
-			return Nil[T](), err
+			return Nil[T](), wrapError(err, "QueryOne", "", "", uuid.Nil)
 		}
 		slog.ErrorContext(ctx, "Failed to scan row", "error", err)
-		return t, err
+		return t, wrapError(TranslateError(err), "QueryOne", "", "", uuid.Nil)
 	}
 	slog.DebugContext(ctx, "Query returned single result")
 	return t, nil
 }
 
 // QueryPage executes a SQL query with pagination and returns a Page of results
+// The paging is validated with DefaultPagingPolicy. If paging.SkipCount is set, countStmt isn't executed and may be nil
 func QueryPage[T any](ctx context.Context, tx *sql.Tx, countStmt, stmt *sql.Stmt, paging Paging, newReceiver func() T, dstFields func(T) []any, args ...any) (Page[T], error) {
+	queryArgs := func(limit, offset int) ([]any, error) {
+		return append(slices.Clone(args), DefaultDialect.LimitOffsetArgs(limit, offset)...), nil
+	}
+	res, err := queryPage(ctx, tx, countStmt, stmt, paging, DefaultPagingPolicy, newReceiver, dstFields, args, queryArgs)
+	return res, wrapError(err, "QueryPage", "", "", uuid.Nil)
+}
+
+// queryPage executes a paginated query with the count arguments and the query arguments built for the page's limit and offset
+func queryPage[T any](ctx context.Context, tx *sql.Tx, countStmt, stmt *sql.Stmt, paging Paging, policy *PagingPolicy, newReceiver func() T, dstFields func(T) []any, countArgs []any, queryArgs func(limit, offset int) ([]any, error)) (Page[T], error) {
 	slog.DebugContext(ctx, "Executing paginated SQL query", "paging", paging)
-	count, err := QueryVal[int](ctx, tx, countStmt, args...)
+	if err := policy.Apply(&paging); err != nil {
+		slog.ErrorContext(ctx, "Invalid paging for paginated query", "paging", paging, "error", err)
+		return Page[T]{}, err
+	}
+
+	if paging.SkipCount {
+		// fetch one more item to find out whether there is a next page
+		args, err := queryArgs(paging.GetLimit()+1, paging.GetOffset())
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to bind arguments for paginated query", "error", err)
+			return Page[T]{}, err
+		}
+		items, err := Query(ctx, tx, stmt, newReceiver, dstFields, args...)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to get items for paginated query", "error", err)
+			return Page[T]{}, err
+		}
+		hasNext := len(items) > paging.PageSize
+		if hasNext {
+			items = items[:paging.PageSize]
+		}
+		result := Page[T]{
+			Items:      items,
+			TotalPages: -1,
+			TotalItems: -1,
+			PageNum:    paging.PageNum,
+			PageSize:   paging.PageSize,
+			HasNext:    hasNext,
+			HasPrev:    paging.PageNum > 1,
+		}
+		slog.DebugContext(ctx, "Paginated query completed without count", "returned_items", len(items), "has_next", hasNext)
+		return result, nil
+	}
+
+	count, err := QueryVal[int](ctx, tx, countStmt, countArgs...)
 	if err != nil {
 		slog.ErrorContext(ctx, "Failed to get count for paginated query", "error", err)
 		return Page[T]{}, err
 	}
 
-	paging.Normalize()
-	items, err := Query(ctx, tx, stmt, newReceiver, dstFields, append(args, paging.GetLimit(), paging.GetOffset())...)
+	args, err := queryArgs(paging.GetLimit(), paging.GetOffset())
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to bind arguments for paginated query", "error", err)
+		return Page[T]{}, err
+	}
+	items, err := Query(ctx, tx, stmt, newReceiver, dstFields, args...)
 	if err != nil {
 		slog.ErrorContext(ctx, "Failed to get items for paginated query", "error", err)
 		return Page[T]{}, err
 	}
 
-	result := Page[T]{Items: items, TotalPages: paging.GetTotalPages(count)}
+	totalPages := paging.GetTotalPages(count)
+	result := Page[T]{
+		Items:      items,
+		TotalPages: totalPages,
+		TotalItems: count,
+		PageNum:    paging.PageNum,
+		PageSize:   paging.PageSize,
+		HasNext:    paging.PageNum < totalPages,
+		HasPrev:    paging.PageNum > 1,
+	}
 	slog.DebugContext(ctx, "Paginated query completed", "total_items", count, "returned_items", len(items), "total_pages", result.TotalPages)
 	return result, nil
 }
@@ -137,88 +419,183 @@ func QueryPage[T any](ctx context.Context, tx *sql.Tx, countStmt, stmt *sql.Stmt
 // QueryVal executes a SQL query and returns a single scalar value
 func QueryVal[T any](ctx context.Context, tx *sql.Tx, stmt *sql.Stmt, args ...any) (T, error) {
 	slog.DebugContext(ctx, "Executing SQL query for scalar value", "stmt", stmt, "args_count", len(args))
-	row := tx.StmtContext(ctx, stmt).QueryRowContext(ctx, args...)
+	row := bind(ctx, tx, stmt).QueryRowContext(ctx, args...)
 
 	var t T
 	if err := row.Scan(&t); err != nil {
 		slog.ErrorContext(ctx, "Failed to scan scalar value", "error", err)
-		return t, err
+		return t, wrapError(TranslateError(err), "QueryVal", "", "", uuid.Nil)
 	}
 	slog.DebugContext(ctx, "Query returned scalar value")
 	return t, nil
 }
 
 // ExecWithTx executes an operation within a transaction
-// If a transaction already exists in the context, it will be reused
+// If a transaction of the same database already exists in the context, it will be reused, unless the context requests a different propagation
+// With Never or Supports propagation and no transaction in the context, the operation runs without a transaction and is passed a nil tx
 func ExecWithTx(ctx context.Context, db *sql.DB, opts *sql.TxOptions, operation func(context.Context, *sql.Tx) error) error {
-	tx := ctx.Value(TxKey)
-	if tx == nil {
-		slog.DebugContext(ctx, "Starting new transaction", "read_only", opts.ReadOnly)
-		tx, err := db.BeginTx(ctx, opts)
-		if err != nil {
-			slog.ErrorContext(ctx, "Failed to begin transaction", "error", err)
-			return err
-		}
-		defer tx.Rollback()
+	_, err := queryWithTx(ctx, db, opts, func(ctx context.Context, tx *sql.Tx) (struct{}, error) {
+		return struct{}{}, operation(ctx, tx)
+	})
+	return wrapTxError(err, "ExecWithTx")
+}
 
-		ctx = context.WithValue(ctx, TxKey, tx)
+// QueryWithTx executes an operation that returns a result within a transaction
+// If a transaction of the same database already exists in the context, it will be reused, unless the context requests a different propagation
+// With Never or Supports propagation and no transaction in the context, the operation runs without a transaction and is passed a nil tx
+func QueryWithTx[T any](ctx context.Context, db *sql.DB, opts *sql.TxOptions, operation func(context.Context, *sql.Tx) (T, error)) (T, error) {
+	res, err := queryWithTx(ctx, db, opts, operation)
+	return res, wrapTxError(err, "QueryWithTx")
+}
 
-		if err := operation(ctx, tx); err != nil {
-			slog.ErrorContext(ctx, "Operation failed within transaction", "error", err)
-			return err
-		}
+func queryWithTx[T any](ctx context.Context, db *sql.DB, opts *sql.TxOptions, operation func(context.Context, *sql.Tx) (T, error)) (T, error) {
+	propagation := propagationFromContext(ctx)
+	if propagation != Required {
+		// propagation applies to this call only, nested calls made by the operation join as usual
+		ctx = WithPropagation(ctx, Required)
+	}
 
-		slog.DebugContext(ctx, "Committing transaction")
-		return tx.Commit()
-	} else {
-		slog.DebugContext(ctx, "Reusing existing transaction from context")
-		ctx = context.WithValue(ctx, TxKey, tx)
-		if err := operation(ctx, tx.(*sql.Tx)); err != nil {
-			slog.ErrorContext(ctx, "Operation failed within transaction", "error", err)
-			return err
-		}
+	state, ok := txStateFromContext(ctx, db)
+	switch {
+	case propagation == Mandatory && !ok:
+		slog.ErrorContext(ctx, "No transaction in context for mandatory propagation")
+		return Nil[T](), ErrNoTransaction
+	case propagation == Never && ok:
+		slog.ErrorContext(ctx, "Transaction exists in context for never propagation")
+		return Nil[T](), ErrTransactionExists
+	case !ok && (propagation == Never || propagation == Supports):
+		return queryWithoutTx(ctx, db, operation)
+	case !ok || propagation == RequiresNew:
+		return queryWithNewTx(ctx, db, opts, operation)
+	}
+
+	if err := state.checkOptions(ctx, opts); err != nil {
+		return Nil[T](), err
+	}
+	if propagation == Nested {
+		return queryWithSavepoint(ctx, db, state, operation)
+	}
+
+	slog.DebugContext(ctx, "Reusing existing transaction from context")
+	res, err := operation(ctx, state.tx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Operation failed within transaction", "error", err)
+		return res, err
+	}
+	return res, nil
+}
+
+// checkOptions verifies that an operation with the given options can join the transaction
+// Transactions that weren't started by gosql are joined without checks since their options are unknown,
+// as are transactions with the default isolation level regarding the isolation level
+func (state *txState) checkOptions(ctx context.Context, opts *sql.TxOptions) error {
+	current := state.opts
+	if current == nil || opts == nil {
 		return nil
 	}
+	if current.ReadOnly && !opts.ReadOnly {
+		slog.ErrorContext(ctx, "Read-write operation cannot join read-only transaction")
+		return ErrReadOnlyTransaction
+	}
+	// the level of the database's default isolation is unknown, so it isn't compared
+	if opts.Isolation != sql.LevelDefault && current.Isolation != sql.LevelDefault && current.Isolation < opts.Isolation {
+		slog.ErrorContext(ctx, "Transaction isolation level is weaker than requested", "current", current.Isolation, "requested", opts.Isolation)
+		return ErrIsolationLevelMismatch
+	}
+	return nil
 }
 
-// QueryWithTx executes an operation that returns a result within a transaction
-// If a transaction already exists in the context, it will be reused
-func QueryWithTx[T any](ctx context.Context, db *sql.DB, opts *sql.TxOptions, operation func(context.Context, *sql.Tx) (T, error)) (T, error) {
-	tx := ctx.Value(TxKey)
-	if tx == nil {
-		slog.DebugContext(ctx, "Starting new transaction for query", "read_only", opts.ReadOnly)
-		tx, err := db.BeginTx(ctx, opts)
-		if err != nil {
-			var t T
-			slog.ErrorContext(ctx, "Failed to begin transaction for query", "error", err)
-			return t, err
-		}
-		defer tx.Rollback()
+// queryWithoutTx runs the operation with a nil transaction, so that the gosql statements it executes run directly
+// on the database and are committed on their own
+func queryWithoutTx[T any](ctx context.Context, db *sql.DB, operation func(context.Context, *sql.Tx) (T, error)) (T, error) {
+	slog.DebugContext(ctx, "Running operation without transaction")
+	ctx = context.WithValue(ctx, txDBKey{}, db)
+	res, err := operation(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Operation failed without transaction", "error", err)
+		return res, err
+	}
+	return res, nil
+}
 
-		ctx = context.WithValue(ctx, TxKey, tx)
+// queryWithNewTx begins a new transaction, runs the operation within it and commits it if the operation succeeds
+// Lifecycle hooks registered within the operation are run around the commit or rollback
+func queryWithNewTx[T any](ctx context.Context, db *sql.DB, opts *sql.TxOptions, operation func(context.Context, *sql.Tx) (T, error)) (T, error) {
+	slog.DebugContext(ctx, "Starting new transaction", "read_only", opts.ReadOnly)
+	tx, err := db.BeginTx(ctx, opts)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to begin transaction", "error", err)
+		return Nil[T](), err
+	}
+	defer tx.Rollback()
 
-		res, err := operation(ctx, tx)
-		if err != nil {
-			slog.ErrorContext(ctx, "Query operation failed within transaction", "error", err)
-			return res, err
-		}
+	hooks := &txHooks{}
+	outerCtx := ctx
+	ctx = context.WithValue(ctx, txDBKey{tx: tx}, db)
+	ctx = context.WithValue(ctx, txKey{db: db}, &txState{tx: tx, opts: opts, hooks: hooks})
 
-		slog.DebugContext(ctx, "Committing transaction after query")
-		if err = tx.Commit(); err != nil {
-			slog.ErrorContext(ctx, "Failed to commit transaction after query", "error", err)
-			return res, err
+	res, err := operation(ctx, tx)
+	if err == nil {
+		err = hooks.runBeforeCommit(ctx)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Operation failed within transaction", "error", err)
+		if rbErr := tx.Rollback(); rbErr != nil {
+			slog.ErrorContext(ctx, "Failed to roll back transaction", "error", rbErr)
 		}
+		hooks.runAfterRollback(outerCtx)
+		return res, err
+	}
+
+	slog.DebugContext(ctx, "Committing transaction")
+	if err = tx.Commit(); err != nil {
+		slog.ErrorContext(ctx, "Failed to commit transaction", "error", err)
+		hooks.runAfterRollback(outerCtx)
+		// deferred constraints are checked on commit
+		return res, TranslateError(err)
+	}
+	hooks.runAfterCommit(outerCtx)
+	return res, nil
+}
 
-		return res, nil
-	} else {
-		slog.DebugContext(ctx, "Reusing existing transaction from context for query")
-		ctx = context.WithValue(ctx, TxKey, tx)
+// queryWithSavepoint runs the operation within a savepoint of the existing transaction
+// The savepoint is rolled back if the operation fails, leaving the rest of the transaction intact
+func queryWithSavepoint[T any](ctx context.Context, db *sql.DB, parent *txState, operation func(context.Context, *sql.Tx) (T, error)) (T, error) {
+	tx := parent.tx
+	name := fmt.Sprintf("gosql_sp_%d", parent.savepoints+1)
 
-		res, err := operation(ctx, tx.(*sql.Tx))
-		if err != nil {
-			slog.ErrorContext(ctx, "Query operation failed within transaction", "error", err)
-			return res, err
+	slog.DebugContext(ctx, "Creating savepoint in existing transaction", "savepoint", name)
+	if _, err := tx.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		slog.ErrorContext(ctx, "Failed to create savepoint", "savepoint", name, "error", err)
+		return Nil[T](), err
+	}
+
+	parentHooks := parent.hooks
+	hooks := &txHooks{}
+	ctx = context.WithValue(ctx, txKey{db: db}, &txState{tx: tx, opts: parent.opts, hooks: hooks, savepoints: parent.savepoints + 1})
+
+	res, err := operation(ctx, tx)
+	if err != nil {
+		slog.ErrorContext(ctx, "Operation failed within savepoint, rolling back to savepoint", "savepoint", name, "error", err)
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			slog.ErrorContext(ctx, "Failed to roll back to savepoint", "savepoint", name, "error", rbErr)
+			parentHooks.mergeAfterRollback(hooks)
+			return res, errors.Join(err, rbErr)
 		}
-		return res, nil
+		hooks.runAfterRollback(ctx)
+		if _, relErr := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); relErr != nil {
+			slog.ErrorContext(ctx, "Failed to release savepoint", "savepoint", name, "error", relErr)
+			return res, errors.Join(err, relErr)
+		}
+		return res, err
+	}
+
+	slog.DebugContext(ctx, "Releasing savepoint", "savepoint", name)
+	if _, err := tx.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		slog.ErrorContext(ctx, "Failed to release savepoint", "savepoint", name, "error", err)
+		parentHooks.mergeAfterRollback(hooks)
+		return res, err
 	}
+	parentHooks.merge(hooks)
+	return res, nil
 }
@@ -3,7 +3,11 @@ package gosql
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
 	"testing"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -82,6 +86,89 @@ func TestPaging(t *testing.T) {
 	}
 }
 
+func TestPagingPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		policy       PagingPolicy
+		paging       Paging
+		expectedPage int
+		expectedSize int
+		expectedErr  error
+	}{
+		{
+			name:         "Unset values use defaults",
+			policy:       PagingPolicy{DefaultPageSize: 50, MaxPageSize: 100, Reject: true},
+			paging:       Paging{},
+			expectedPage: 1,
+			expectedSize: 50,
+		},
+		{
+			name:         "Default page size is limited by max page size",
+			policy:       PagingPolicy{DefaultPageSize: 50, MaxPageSize: 10},
+			paging:       Paging{},
+			expectedPage: 1,
+			expectedSize: 10,
+		},
+		{
+			name:         "Invalid fields are clamped independently",
+			policy:       PagingPolicy{DefaultPageSize: 50, MaxPageSize: 100},
+			paging:       Paging{PageNum: 3, PageSize: -1},
+			expectedPage: 3,
+			expectedSize: 50,
+		},
+		{
+			name:         "Oversized page is clamped",
+			policy:       PagingPolicy{MaxPageSize: 100},
+			paging:       Paging{PageNum: -2, PageSize: 500},
+			expectedPage: 1,
+			expectedSize: 100,
+		},
+		{
+			name:         "No max page size",
+			policy:       PagingPolicy{Reject: true},
+			paging:       Paging{PageNum: 2, PageSize: 5000},
+			expectedPage: 2,
+			expectedSize: 5000,
+		},
+		{
+			name:        "Negative page number is rejected",
+			policy:      PagingPolicy{MaxPageSize: 100, Reject: true},
+			paging:      Paging{PageNum: -1, PageSize: 10},
+			expectedErr: ErrInvalidPaging,
+		},
+		{
+			name:        "Negative page size is rejected",
+			policy:      PagingPolicy{MaxPageSize: 100, Reject: true},
+			paging:      Paging{PageNum: 1, PageSize: -10},
+			expectedErr: ErrInvalidPaging,
+		},
+		{
+			name:        "Oversized page is rejected",
+			policy:      PagingPolicy{MaxPageSize: 100, Reject: true},
+			paging:      Paging{PageNum: 1, PageSize: 101},
+			expectedErr: ErrInvalidPaging,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Apply(&tt.paging)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if tt.paging.PageNum != tt.expectedPage {
+				t.Errorf("Expected page number %d, got %d", tt.expectedPage, tt.paging.PageNum)
+			}
+			if tt.paging.PageSize != tt.expectedSize {
+				t.Errorf("Expected page size %d, got %d", tt.expectedSize, tt.paging.PageSize)
+			}
+		})
+	}
+}
+
 func TestExecWithTxNested(t *testing.T) {
 	db, err := sql.Open("sqlite3", ":memory:")
 	if err != nil {
@@ -205,6 +292,455 @@ func TestQueryWithTxNested(t *testing.T) {
 	}
 }
 
+func TestExecWithTxNestedSavepoint(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Create test table
+	_, err = db.Exec(`CREATE TABLE test (id INTEGER PRIMARY KEY, value TEXT)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	insert := func(ctx context.Context, tx *sql.Tx, value string) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO test (value) VALUES (?)", value)
+		return err
+	}
+	errInner := errors.New("inner failure")
+
+	ctx := context.Background()
+	err = ExecWithTx(ctx, db, RW, func(ctx context.Context, tx *sql.Tx) error {
+		if err := insert(ctx, tx, "first"); err != nil {
+			return err
+		}
+
+		// Failed nested operation should only roll back its own changes
+		err := ExecWithTx(WithPropagation(ctx, Nested), db, RW, func(ctx context.Context, tx *sql.Tx) error {
+			if err := insert(ctx, tx, "discarded"); err != nil {
+				return err
+			}
+			// Deeper nesting within a savepoint
+			if err := ExecWithTx(WithPropagation(ctx, Nested), db, RW, func(ctx context.Context, tx *sql.Tx) error {
+				return insert(ctx, tx, "discarded too")
+			}); err != nil {
+				return err
+			}
+			return errInner
+		})
+		if !errors.Is(err, errInner) {
+			t.Errorf("Expected inner error, got %v", err)
+		}
+
+		// Successful nested operation keeps its changes
+		return ExecWithTx(WithPropagation(ctx, Nested), db, RW, func(ctx context.Context, tx *sql.Tx) error {
+			return insert(ctx, tx, "second")
+		})
+	})
+	if err != nil {
+		t.Fatalf("Failed to execute nested transactions: %v", err)
+	}
+
+	rows, err := db.Query("SELECT value FROM test ORDER BY id")
+	if err != nil {
+		t.Fatalf("Failed to query rows: %v", err)
+	}
+	defer rows.Close()
+	values := make([]string, 0)
+	for rows.Next() {
+		var v string
+		if err := rows.Scan(&v); err != nil {
+			t.Fatalf("Failed to scan row: %v", err)
+		}
+		values = append(values, v)
+	}
+	if len(values) != 2 || values[0] != "first" || values[1] != "second" {
+		t.Errorf("Expected [first second], got %v", values)
+	}
+}
+
+func TestQueryWithTxNestedSavepoint(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Create test table
+	_, err = db.Exec(`CREATE TABLE test (id INTEGER PRIMARY KEY, value TEXT)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	ctx := context.Background()
+	// Nested propagation without a transaction in the context begins a new one
+	count, err := QueryWithTx(WithPropagation(ctx, Nested), db, RW, func(ctx context.Context, tx *sql.Tx) (int, error) {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO test (value) VALUES ('first')"); err != nil {
+			return 0, err
+		}
+
+		_, err := QueryWithTx(WithPropagation(ctx, Nested), db, RW, func(ctx context.Context, tx *sql.Tx) (int, error) {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO test (value) VALUES ('discarded')"); err != nil {
+				return 0, err
+			}
+			var count int
+			if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM nonexistent_table").Scan(&count); err != nil {
+				return 0, err
+			}
+			return count, nil
+		})
+		if err == nil {
+			t.Error("Expected error from nested query")
+		}
+
+		var count int
+		err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM test").Scan(&count)
+		return count, err
+	})
+	if err != nil {
+		t.Fatalf("Failed to execute nested queries: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected 1 row after nested rollback, got %d", count)
+	}
+}
+
+func TestQueryWithTxPropagation(t *testing.T) {
+	// RequiresNew needs a second connection to the same database
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_journal_mode=WAL&_busy_timeout=1000")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Create test table
+	_, err = db.Exec(`CREATE TABLE test (id INTEGER PRIMARY KEY, value TEXT)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	countRows := func(ctx context.Context, tx *sql.Tx) (int, error) {
+		var count int
+		err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM test").Scan(&count)
+		return count, err
+	}
+	errOuter := errors.New("outer failure")
+
+	ctx := context.Background()
+	err = ExecWithTx(ctx, db, RW, func(ctx context.Context, outerTx *sql.Tx) error {
+		// RequiresNew commits independently of the outer transaction
+		err := ExecWithTx(WithPropagation(ctx, RequiresNew), db, RW, func(ctx context.Context, tx *sql.Tx) error {
+			if tx == outerTx {
+				t.Error("Expected a new transaction for RequiresNew propagation")
+			}
+			_, err := tx.ExecContext(ctx, "INSERT INTO test (value) VALUES ('audit')")
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		// Mandatory and Supports join the outer transaction
+		for _, propagation := range []Propagation{Mandatory, Supports} {
+			joined, err := QueryWithTx(WithPropagation(ctx, propagation), db, RO, func(ctx context.Context, tx *sql.Tx) (bool, error) {
+				return tx == outerTx, nil
+			})
+			if err != nil || !joined {
+				t.Errorf("Expected propagation %d to join the outer transaction, got joined=%v, err=%v", propagation, joined, err)
+			}
+		}
+
+		// Never fails within a transaction
+		_, err = QueryWithTx(WithPropagation(ctx, Never), db, RO, countRows)
+		if !errors.Is(err, ErrTransactionExists) {
+			t.Errorf("Expected ErrTransactionExists, got %v", err)
+		}
+
+		if _, err := outerTx.ExecContext(ctx, "INSERT INTO test (value) VALUES ('data')"); err != nil {
+			return err
+		}
+		return errOuter
+	})
+	if !errors.Is(err, errOuter) {
+		t.Fatalf("Expected outer error, got %v", err)
+	}
+
+	// Mandatory fails without a transaction
+	_, err = QueryWithTx(WithPropagation(ctx, Mandatory), db, RO, countRows)
+	if !errors.Is(err, ErrNoTransaction) {
+		t.Errorf("Expected ErrNoTransaction, got %v", err)
+	}
+
+	// Never and Supports run without a transaction, every statement sees the rows committed before it
+	count := &QueryValStmt[int]{BaseStmt: BaseStmt{Query: "SELECT COUNT(*) FROM test"}}
+	for _, propagation := range []Propagation{Never, Supports} {
+		counts, err := QueryWithTx(WithPropagation(ctx, propagation), db, RO, func(ctx context.Context, tx *sql.Tx) ([]int, error) {
+			if tx != nil {
+				t.Errorf("Expected no transaction for propagation %d", propagation)
+			}
+			before, err := count.Query(ctx, tx)
+			if err != nil {
+				return nil, err
+			}
+			err = ExecWithTx(ctx, db, RW, func(ctx context.Context, tx *sql.Tx) error {
+				_, err := tx.ExecContext(ctx, "INSERT INTO test (value) VALUES ('concurrent')")
+				return err
+			})
+			if err != nil {
+				return nil, err
+			}
+			after, err := count.Query(ctx, tx)
+			return []int{before, after}, err
+		})
+		if err != nil {
+			t.Fatalf("Failed to query with propagation %d: %v", propagation, err)
+		}
+		if counts[1] != counts[0]+1 {
+			t.Errorf("Expected the second statement to see the committed row with propagation %d, got %v", propagation, counts)
+		}
+	}
+	if count, err := QueryWithTx(ctx, db, RO, countRows); err != nil || count != 3 {
+		t.Errorf("Expected the row committed by RequiresNew and the concurrent rows, got %d, %v", count, err)
+	}
+}
+
+func TestQueryWithTxMultipleDatabases(t *testing.T) {
+	openDB := func() *sql.DB {
+		db, err := sql.Open("sqlite3", ":memory:")
+		if err != nil {
+			t.Fatalf("Failed to open database: %v", err)
+		}
+		if _, err = db.Exec(`CREATE TABLE test (id INTEGER PRIMARY KEY, value TEXT)`); err != nil {
+			t.Fatalf("Failed to create table: %v", err)
+		}
+		return db
+	}
+	primary := openDB()
+	defer primary.Close()
+	reporting := openDB()
+	defer reporting.Close()
+
+	err := ExecWithTx(ctx, primary, RW, func(ctx context.Context, primaryTx *sql.Tx) error {
+		if _, ok := TxFromContext(ctx, reporting); ok {
+			t.Error("Expected no reporting transaction in context")
+		}
+		// A transaction of another database is not joined
+		return ExecWithTx(ctx, reporting, RW, func(ctx context.Context, reportingTx *sql.Tx) error {
+			if reportingTx == primaryTx {
+				t.Error("Expected a separate transaction for reporting database")
+			}
+			if tx, ok := TxFromContext(ctx, primary); !ok || tx != primaryTx {
+				t.Error("Expected primary transaction to remain in context")
+			}
+			if tx, ok := TxFromContext(ctx, reporting); !ok || tx != reportingTx {
+				t.Error("Expected reporting transaction in context")
+			}
+			_, err := reportingTx.ExecContext(ctx, "INSERT INTO test (value) VALUES ('report')")
+			return err
+		})
+	})
+	if err != nil {
+		t.Fatalf("Failed to execute transactions: %v", err)
+	}
+
+	// Transactions managed outside of gosql are joined
+	tx, err := primary.BeginTx(ctx, RW)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	joined, err := QueryWithTx(WithTx(ctx, primary, tx), primary, RW, func(ctx context.Context, joinedTx *sql.Tx) (bool, error) {
+		return joinedTx == tx, nil
+	})
+	if err != nil || !joined {
+		t.Errorf("Expected external transaction to be joined, got joined=%v, err=%v", joined, err)
+	}
+	// Transactions stored under the deprecated TxKey are still joined, while WithTx takes precedence
+	legacyCtx := context.WithValue(ctx, TxKey, tx)
+	for db, expected := range map[*sql.DB]*sql.Tx{primary: tx, reporting: tx} {
+		joinedTx, err := QueryWithTx(legacyCtx, db, RW, func(ctx context.Context, joinedTx *sql.Tx) (*sql.Tx, error) {
+			return joinedTx, nil
+		})
+		if err != nil || joinedTx != expected {
+			t.Errorf("Expected transaction under TxKey to be joined, got %v", err)
+		}
+	}
+	reportingTx, err := reporting.BeginTx(ctx, RW)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	if joinedTx, ok := TxFromContext(WithTx(legacyCtx, reporting, reportingTx), reporting); !ok || joinedTx != reportingTx {
+		t.Errorf("Expected the transaction shared with WithTx to take precedence over TxKey")
+	}
+	if err := reportingTx.Rollback(); err != nil {
+		t.Fatalf("Failed to roll back external transaction: %v", err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatalf("Failed to roll back external transaction: %v", err)
+	}
+
+	for db, expected := range map[*sql.DB]int{primary: 0, reporting: 1} {
+		var count int
+		if err := db.QueryRow("SELECT COUNT(*) FROM test").Scan(&count); err != nil {
+			t.Fatalf("Failed to count rows: %v", err)
+		}
+		if count != expected {
+			t.Errorf("Expected %d rows, got %d", expected, count)
+		}
+	}
+}
+
+func TestQueryWithTxOptionsMismatch(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	noop := func(ctx context.Context, tx *sql.Tx) error { return nil }
+	serializable := &sql.TxOptions{Isolation: sql.LevelSerializable}
+
+	// Read-write operations cannot join read-only transactions, including within savepoints
+	err = ExecWithTx(ctx, db, RO, func(ctx context.Context, tx *sql.Tx) error {
+		if err := ExecWithTx(ctx, db, RO, noop); err != nil {
+			t.Errorf("Expected read-only operation to join read-only transaction, got %v", err)
+		}
+		if err := ExecWithTx(WithPropagation(ctx, Nested), db, RW, noop); !errors.Is(err, ErrReadOnlyTransaction) {
+			t.Errorf("Expected ErrReadOnlyTransaction for nested operation, got %v", err)
+		}
+		return ExecWithTx(ctx, db, RW, noop)
+	})
+	if !errors.Is(err, ErrReadOnlyTransaction) {
+		t.Errorf("Expected ErrReadOnlyTransaction, got %v", err)
+	}
+
+	// Read-only operations can join read-write transactions
+	err = ExecWithTx(ctx, db, RW, func(ctx context.Context, tx *sql.Tx) error {
+		return ExecWithTx(ctx, db, RO, noop)
+	})
+	if err != nil {
+		t.Errorf("Expected read-only operation to join read-write transaction, got %v", err)
+	}
+
+	// Transactions with the default isolation level are joined regardless of the requested level
+	err = ExecWithTx(ctx, db, RW, func(ctx context.Context, tx *sql.Tx) error {
+		return ExecWithTx(ctx, db, serializable, noop)
+	})
+	if err != nil {
+		t.Errorf("Expected operation to join transaction with default isolation level, got %v", err)
+	}
+
+	// Operations requesting a stronger isolation level cannot join the transaction
+	err = ExecWithTx(ctx, db, &sql.TxOptions{Isolation: sql.LevelReadUncommitted}, func(ctx context.Context, tx *sql.Tx) error {
+		return ExecWithTx(ctx, db, serializable, noop)
+	})
+	if !errors.Is(err, ErrIsolationLevelMismatch) {
+		t.Errorf("Expected ErrIsolationLevelMismatch, got %v", err)
+	}
+
+	err = ExecWithTx(ctx, db, serializable, func(ctx context.Context, tx *sql.Tx) error {
+		return ExecWithTx(ctx, db, &sql.TxOptions{Isolation: sql.LevelReadCommitted}, noop)
+	})
+	if err != nil {
+		t.Errorf("Expected operation to join transaction with stronger isolation level, got %v", err)
+	}
+}
+
+func TestQueryWithRetry(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	policy := &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	busy := sqlStateErr("40001")
+
+	// Retryable errors are retried until the operation succeeds
+	attempts := 0
+	res, err := QueryWithRetry(ctx, db, RW, policy, func(ctx context.Context, tx *sql.Tx) (int, error) {
+		attempts++
+		if attempts < 3 {
+			return 0, busy
+		}
+		return attempts, nil
+	})
+	if err != nil || res != 3 {
+		t.Errorf("Expected success on 3rd attempt, got res=%d, err=%v", res, err)
+	}
+
+	// Attempts are bounded
+	attempts = 0
+	err = ExecWithRetry(ctx, db, RW, policy, func(ctx context.Context, tx *sql.Tx) error {
+		attempts++
+		return busy
+	})
+	if !errors.Is(err, busy) || attempts != 3 {
+		t.Errorf("Expected busy error after 3 attempts, got %v after %d attempts", err, attempts)
+	}
+
+	// Non-retryable errors are returned immediately
+	attempts = 0
+	errFatal := errors.New("fatal")
+	err = ExecWithRetry(ctx, db, RW, policy, func(ctx context.Context, tx *sql.Tx) error {
+		attempts++
+		return errFatal
+	})
+	if !errors.Is(err, errFatal) || attempts != 1 {
+		t.Errorf("Expected fatal error after 1 attempt, got %v after %d attempts", err, attempts)
+	}
+
+	// Joined transactions are left to the owner to retry
+	attempts = 0
+	err = ExecWithTx(ctx, db, RW, func(ctx context.Context, tx *sql.Tx) error {
+		return ExecWithRetry(ctx, db, RW, policy, func(ctx context.Context, tx *sql.Tx) error {
+			attempts++
+			return busy
+		})
+	})
+	if !errors.Is(err, busy) || attempts != 1 {
+		t.Errorf("Expected busy error after 1 attempt within joined transaction, got %v after %d attempts", err, attempts)
+	}
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "Serialization failure", err: fmt.Errorf("wrapped: %w", sqlStateErr("40001")), expected: true},
+		{name: "Deadlock detected", err: sqlStateErr("40P01"), expected: true},
+		{name: "Unique violation", err: sqlStateErr("23505"), expected: false},
+		{name: "Other error", err: sql.ErrNoRows, expected: false},
+		{name: "Registered classifier", err: errContention, expected: true},
+	}
+	RegisterRetryClassifier(func(err error) bool {
+		return errors.Is(err, errContention)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if IsRetryableError(tt.err) != tt.expected {
+				t.Errorf("Expected IsRetryableError to return %v for %v", tt.expected, tt.err)
+			}
+		})
+	}
+}
+
+var errContention = errors.New("contention")
+
+type sqlStateErr string
+
+func (e sqlStateErr) Error() string {
+	return "sql state " + string(e)
+}
+
+func (e sqlStateErr) SQLState() string {
+	return string(e)
+}
+
 func TestQueryValNoRows(t *testing.T) {
 	db, err := sql.Open("sqlite3", ":memory:")
 	if err != nil {
@@ -232,7 +768,7 @@ func TestQueryValNoRows(t *testing.T) {
 	defer stmt.Close()
 
 	_, err = QueryVal[string](ctx, tx, stmt, 1)
-	if err != sql.ErrNoRows {
+	if !errors.Is(err, sql.ErrNoRows) {
 		t.Errorf("Expected sql.ErrNoRows, got %v", err)
 	}
 }
@@ -273,7 +809,7 @@ func TestQueryOneNoRows(t *testing.T) {
 		func(t *TestStruct) []any { return []any{&t.ID, &t.Value} },
 		1)
 
-	if err != sql.ErrNoRows {
+	if !errors.Is(err, sql.ErrNoRows) {
 		t.Errorf("Expected sql.ErrNoRows, got %v", err)
 	}
 
@@ -392,6 +928,93 @@ func TestQueryPageError(t *testing.T) {
 	}
 }
 
+func TestStream(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Create test table and insert test data
+	_, err = db.Exec(`
+		CREATE TABLE test (id INTEGER PRIMARY KEY, value TEXT);
+		INSERT INTO test (value) VALUES ('a'), ('b'), ('c'), ('d');
+	`)
+	if err != nil {
+		t.Fatalf("Failed to setup test data: %v", err)
+	}
+
+	type TestStruct struct {
+		ID    int
+		Value string
+	}
+	newReceiver := func() *TestStruct { return &TestStruct{} }
+	receive := func(t *TestStruct) []any { return []any{&t.ID, &t.Value} }
+
+	err = ExecWithTx(ctx, db, RW, func(ctx context.Context, tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, "SELECT id, value FROM test ORDER BY id")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		values := ""
+		for item, err := range Stream(ctx, tx, stmt, newReceiver, receive) {
+			if err != nil {
+				return err
+			}
+			values += item.Value
+		}
+		if values != "abcd" {
+			t.Errorf("Expected all values streamed, got %s", values)
+		}
+
+		// Breaking early closes the rows, so the transaction can be used further
+		values = ""
+		for item, err := range Stream(ctx, tx, stmt, newReceiver, receive) {
+			if err != nil {
+				return err
+			}
+			values += item.Value
+			if len(values) == 2 {
+				break
+			}
+		}
+		if values != "ab" {
+			t.Errorf("Expected streaming to stop after 2 values, got %s", values)
+		}
+		_, err = tx.ExecContext(ctx, "INSERT INTO test (value) VALUES ('e')")
+		return err
+	})
+	if err != nil {
+		t.Fatalf("Failed to stream query results: %v", err)
+	}
+
+	// Scan errors are yielded and stop the iteration
+	err = ExecWithTx(ctx, db, RO, func(ctx context.Context, tx *sql.Tx) error {
+		stmt, err := tx.PrepareContext(ctx, "SELECT value, id FROM test")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		yielded := 0
+		for _, err := range Stream(ctx, tx, stmt, newReceiver, receive) {
+			yielded++
+			if err == nil {
+				t.Error("Expected scan error")
+			}
+		}
+		if yielded != 1 {
+			t.Errorf("Expected a single error to be yielded, got %d items", yielded)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Failed to execute transaction: %v", err)
+	}
+}
+
 func TestNilHelpers(t *testing.T) {
 	type TestStruct struct {
 		Value string
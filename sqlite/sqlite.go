@@ -0,0 +1,56 @@
+// Package sqlite classifies the errors of the github.com/mattn/go-sqlite3 driver for gosql.
+//
+// Importing the package registers IsRetryableError with gosql.RegisterRetryClassifier, so that
+// gosql.ExecWithRetry and gosql.QueryWithRetry retry busy and locked databases, and TranslateError with
+// gosql.RegisterErrorTranslator, so that constraint errors are returned as *gosql.ConstraintError:
+//
+//	import _ "github.com/iglin/go-sql/sqlite"
+package sqlite
+
+import (
+	"errors"
+	"strings"
+
+	gosql "github.com/iglin/go-sql"
+	"github.com/mattn/go-sqlite3"
+)
+
+func init() {
+	gosql.RegisterRetryClassifier(IsRetryableError)
+	gosql.RegisterErrorTranslator(TranslateError)
+}
+
+// IsRetryableError reports whether the error is a SQLite busy or locked error that is worth retrying the whole transaction for
+func IsRetryableError(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
+
+// TranslateError translates the constraint errors of SQLite into gosql constraint violations by their extended codes
+func TranslateError(err error) *gosql.ConstraintError {
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+		return nil
+	}
+
+	var kind error
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		kind = gosql.ErrUniqueViolation
+	case sqlite3.ErrConstraintForeignKey:
+		kind = gosql.ErrForeignKeyViolation
+	case sqlite3.ErrConstraintNotNull:
+		kind = gosql.ErrNotNullViolation
+	case sqlite3.ErrConstraintCheck:
+		kind = gosql.ErrCheckViolation
+	default:
+		return nil
+	}
+	// SQLite names the constraint after the message, e.g. "UNIQUE constraint failed: users.email",
+	// except for foreign keys
+	_, constraint, _ := strings.Cut(sqliteErr.Error(), "constraint failed: ")
+	return &gosql.ConstraintError{Kind: kind, Constraint: constraint, Err: err}
+}
@@ -0,0 +1,166 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	gosql "github.com/iglin/go-sql"
+	"github.com/mattn/go-sqlite3"
+)
+
+var ctx = context.Background()
+
+type department struct {
+	gosql.GenericEntity
+	Name string `db:"name"`
+}
+
+func (d *department) Equals(another any) bool {
+	anotherDpt, ok := another.(*department)
+	return ok && d.Name == anotherDpt.Name
+}
+
+type student struct {
+	gosql.GenericEntity
+	Name       string      `db:"name"`
+	Department *department `db:"department_id,ref=id"`
+}
+
+func (s *student) Equals(another any) bool {
+	anotherStudent, ok := another.(*student)
+	return ok && s.Name == anotherStudent.Name
+}
+
+func newDao[T gosql.Entity](t *testing.T, db *sql.DB, table string) gosql.Dao[T] {
+	dao, err := gosql.DaoBuilder[T]{
+		DB:             db,
+		Table:          &gosql.Table{Name: table},
+		SaveChildren:   func(ctx context.Context, tx *sql.Tx, e T) error { return nil },
+		LoadChildren:   func(ctx context.Context, tx *sql.Tx, e T) error { return nil },
+		DeleteChildren: func(ctx context.Context, tx *sql.Tx, e T) error { return nil },
+	}.Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create DAO: %v", err)
+	}
+	return dao
+}
+
+func TestIsRetryableError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "Busy", err: sqlite3.Error{Code: sqlite3.ErrBusy}, expected: true},
+		{name: "Locked", err: fmt.Errorf("wrapped: %w", sqlite3.Error{Code: sqlite3.ErrLocked}), expected: true},
+		{name: "Constraint", err: sqlite3.Error{Code: sqlite3.ErrConstraint}, expected: false},
+		{name: "Other error", err: sql.ErrNoRows, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if IsRetryableError(tt.err) != tt.expected {
+				t.Errorf("Expected IsRetryableError to return %v for %v", tt.expected, tt.err)
+			}
+			// the classifier is registered on import
+			if gosql.IsRetryableError(tt.err) != tt.expected {
+				t.Errorf("Expected gosql.IsRetryableError to return %v for %v", tt.expected, tt.err)
+			}
+		})
+	}
+}
+
+func TestTranslateError(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_busy_timeout=1000&_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	_, err = db.Exec(`
+		CREATE TABLE departments (id TEXT PRIMARY KEY, version TEXT NOT NULL, name TEXT NOT NULL UNIQUE);
+		CREATE TABLE students (
+			id TEXT PRIMARY KEY,
+			version TEXT NOT NULL,
+			name TEXT NOT NULL,
+			department_id TEXT NOT NULL REFERENCES departments (id)
+		);
+		CREATE TABLE grades (
+			student_id TEXT REFERENCES students (id) DEFERRABLE INITIALLY DEFERRED,
+			score INTEGER CONSTRAINT score_range CHECK (score BETWEEN 0 AND 100)
+		);
+	`)
+	if err != nil {
+		t.Fatalf("Failed to create tables: %v", err)
+	}
+
+	departmentDao := newDao[*department](t, db, "departments")
+	defer departmentDao.Close(ctx)
+	studentDao := newDao[*student](t, db, "students")
+	defer studentDao.Close(ctx)
+
+	math := &department{Name: "Math"}
+	if err := departmentDao.Save(ctx, math); err != nil {
+		t.Fatalf("Failed to save department: %v", err)
+	}
+	if err := studentDao.Save(ctx, &student{Name: "John", Department: math}); err != nil {
+		t.Fatalf("Failed to save student: %v", err)
+	}
+
+	assertViolation := func(name string, err, kind error, constraint string) {
+		t.Helper()
+		var constraintErr *gosql.ConstraintError
+		if !errors.As(err, &constraintErr) || !errors.Is(err, kind) {
+			t.Fatalf("%s: expected %v, got %v", name, kind, err)
+		}
+		if constraintErr.Constraint != constraint {
+			t.Errorf("%s: expected constraint %q, got %q", name, constraint, constraintErr.Constraint)
+		}
+		var sqliteErr sqlite3.Error
+		if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+			t.Errorf("%s: expected the original sqlite3 error, got %v", name, constraintErr.Err)
+		}
+	}
+
+	assertViolation("Save", departmentDao.Save(ctx, &department{Name: "Math"}), gosql.ErrUniqueViolation, "departments.name")
+	assertViolation("Save", studentDao.Save(ctx, &student{Name: "Jane", Department: &department{}}), gosql.ErrForeignKeyViolation, "")
+	assertViolation("Delete", departmentDao.Delete(ctx, math), gosql.ErrForeignKeyViolation, "")
+
+	insert := &gosql.ExecStmt{BaseStmt: gosql.BaseStmt{Query: "INSERT INTO students (id, version, name, department_id) VALUES (?, ?, ?, ?)"}}
+	err = gosql.ExecWithTx(ctx, db, gosql.RW, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := insert.Exec(ctx, tx, uuid.New(), uuid.New(), nil, math.ID)
+		return err
+	})
+	assertViolation("Exec", err, gosql.ErrNotNullViolation, "students.name")
+
+	insertGrade := &gosql.QueryValStmt[int]{BaseStmt: gosql.BaseStmt{Query: "INSERT INTO grades (score) VALUES (?) RETURNING score"}}
+	err = gosql.ExecWithTx(ctx, db, gosql.RW, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := insertGrade.Query(ctx, tx, 101)
+		return err
+	})
+	assertViolation("Query", err, gosql.ErrCheckViolation, "score_range")
+
+	// deferred constraints fail the commit
+	err = gosql.ExecWithTx(ctx, db, gosql.RW, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "INSERT INTO grades (student_id, score) VALUES (?, ?)", uuid.New(), 90)
+		return err
+	})
+	assertViolation("Commit", err, gosql.ErrForeignKeyViolation, "")
+
+	if count, err := departmentDao.CountBy(ctx, nil); err != nil || count != 1 {
+		t.Errorf("Expected the department to be kept, got %d, %v", count, err)
+	}
+
+	// other constraints are returned as is
+	trigger := sqlite3.Error{Code: sqlite3.ErrConstraint, ExtendedCode: sqlite3.ErrConstraintTrigger}
+	if TranslateError(trigger) != nil {
+		t.Errorf("Expected no translation of a trigger constraint")
+	}
+	if TranslateError(sqlite3.Error{Code: sqlite3.ErrBusy}) != nil {
+		t.Errorf("Expected no translation of a busy error")
+	}
+}
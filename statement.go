@@ -4,16 +4,32 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"iter"
 	"log/slog"
+	"slices"
+	"sync"
+
+	"github.com/google/uuid"
 )
 
 // BaseStmt represents the base structure for all statement types
 type BaseStmt struct {
-	Query      string
-	Cache      bool
-	cachedStmt *sql.Stmt
+	Query     string
+	Cache     bool
+	stmtCache *StmtCache
+	dialect   Dialect
+	// cachedIn is the set of caches and databases the statement and the statements derived from it are cached in,
+	// so that Close only releases the cached statements of these databases, guarded by cachedInMu
+	cachedIn map[cachedInKey]struct{}
+}
+
+type cachedInKey struct {
+	cache *StmtCache
+	db    *sql.DB
 }
 
+var cachedInMu sync.Mutex
+
 // DaoExecStmt represents a statement that executes a command without returning rows
 type DaoExecStmt struct {
 	Query string
@@ -94,138 +110,303 @@ func (s *DaoQueryPageStmt[T]) ToStmt(newReceiver func() T, receive func(T) []any
 }
 
 // QueryPageStmt represents a statement that returns a paginated result set
+// QueryStmt must end with the dialect's LimitOffset clause, e.g. LIMIT ? OFFSET ?, as its arguments follow the query's arguments
 type QueryPageStmt[T any] struct {
 	CountStmt *QueryValStmt[int]
 	QueryStmt *QueryStmt[T]
 }
 
-// prepare prepares a statement for execution, using a cached version if available
-func (stmt *BaseStmt) prepare(ctx context.Context, tx *sql.Tx) (*sql.Stmt, error) {
-	if stmt.Cache && stmt.cachedStmt != nil {
-		return stmt.cachedStmt, nil
+// prepare prepares a statement for execution, using a cached version if available, and binds the arguments to it
+// Named parameters of the query are rewritten to positional placeholders and the arguments are converted accordingly,
+// slice arguments are expanded into multiple placeholders and the placeholders are rebound to the statement's dialect.
+// Statements with expanded arguments are never cached.
+// Cached statements are prepared on the database the transaction belongs to, so that they outlive the transaction
+// and can be rebound to any other transaction of the same database. The returned flag reports whether the statement
+// is cached and must not be closed by the caller
+func (stmt *BaseStmt) prepare(ctx context.Context, tx *sql.Tx, args []any) (*sql.Stmt, []any, bool, error) {
+	query, args, expanded, err := parseNamedQuery(stmt.Query).prepareArgs(args, stmt.getDialect())
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to bind named arguments", "query", stmt.Query, "error", err)
+		return nil, nil, false, err
 	}
-	var err error
+	stmtToUse, cached, err := stmt.prepareQuery(ctx, tx, query, !expanded)
+	return stmtToUse, args, cached, err
+}
+
+// prepareQuery prepares the query rewritten to positional placeholders, using the cache if allowed, see prepare
+func (stmt *BaseStmt) prepareQuery(ctx context.Context, tx *sql.Tx, query string, cacheable bool) (*sql.Stmt, bool, error) {
+	if stmt.Cache && cacheable {
+		if db, ok := dbFromContext(ctx, tx); ok {
+			cache := stmt.getStmtCache()
+			stmt.setCachedIn(cache, db)
+			if tx == nil || db.Stats().Idle > 0 {
+				stmtToUse, err := cache.prepare(ctx, db, query)
+				if err != nil {
+					return nil, false, err
+				}
+				return stmtToUse, true, nil
+			}
+			if stmtToUse, ok := cache.get(db, query); ok {
+				return stmtToUse, true, nil
+			}
+			// the transaction holds the only available connection, preparing on the database would block or open
+			// a connection to a different in-memory database, so the statement is cached once the transaction commits
+			slog.DebugContext(ctx, "No idle connection to prepare cached statement on, preparing it within transaction", "query", stmt.Query)
+			cache.prepareAfterCommit(ctx, db, query)
+		} else {
+			slog.DebugContext(ctx, "Transaction wasn't started by gosql, preparing statement without caching", "query", stmt.Query)
+		}
+	}
+
 	var stmtToUse *sql.Stmt
-	stmtToUse, err = tx.PrepareContext(ctx, stmt.Query)
+	var err error
+	if tx != nil {
+		stmtToUse, err = tx.PrepareContext(ctx, query)
+	} else if db, ok := dbFromContext(ctx, nil); ok {
+		stmtToUse, err = db.PrepareContext(ctx, query)
+	} else {
+		slog.ErrorContext(ctx, "No transaction or database in context to prepare statement", "query", stmt.Query)
+		return nil, false, ErrNoTransaction
+	}
 	if err != nil {
-		slog.ErrorContext(ctx, "Failed to prepare cached statement", "query", stmt.Query, "error", err)
-		return nil, err
+		slog.ErrorContext(ctx, "Failed to prepare statement", "query", stmt.Query, "error", err)
+		return nil, false, err
+	}
+	return stmtToUse, false, nil
+}
+
+// derive returns a statement of the query that uses the statement's cache and dialect and shares its cached-in set,
+// so that closing the statement also releases the cached statements of the derived one
+func (stmt *BaseStmt) derive(query string, cache bool) BaseStmt {
+	cachedInMu.Lock()
+	defer cachedInMu.Unlock()
+	if stmt.cachedIn == nil {
+		stmt.cachedIn = make(map[cachedInKey]struct{})
+	}
+	return BaseStmt{Query: query, Cache: cache, stmtCache: stmt.stmtCache, dialect: stmt.dialect, cachedIn: stmt.cachedIn}
+}
+
+func (stmt *BaseStmt) setCachedIn(cache *StmtCache, db *sql.DB) {
+	cachedInMu.Lock()
+	defer cachedInMu.Unlock()
+	if stmt.cachedIn == nil {
+		stmt.cachedIn = make(map[cachedInKey]struct{})
 	}
-	if stmt.Cache {
-		stmt.cachedStmt = stmtToUse
+	stmt.cachedIn[cachedInKey{cache: cache, db: db}] = struct{}{}
+}
+
+func (stmt *BaseStmt) getCachedIn() []cachedInKey {
+	cachedInMu.Lock()
+	defer cachedInMu.Unlock()
+	keys := make([]cachedInKey, 0, len(stmt.cachedIn))
+	for key := range stmt.cachedIn {
+		keys = append(keys, key)
 	}
-	return stmtToUse, nil
+	return keys
 }
 
-// Exec executes a gosql statement with the given arguments
-func (stmt *ExecStmt) Exec(ctx context.Context, tx *sql.Tx, args ...any) error {
+func (stmt *BaseStmt) getStmtCache() *StmtCache {
+	if stmt.stmtCache == nil {
+		return DefaultStmtCache
+	}
+	return stmt.stmtCache
+}
+
+func (stmt *BaseStmt) getDialect() Dialect {
+	if stmt.dialect == nil {
+		return DefaultDialect
+	}
+	return stmt.dialect
+}
+
+// error returns err as an *Error of the statement's operation, nil if err is nil
+func (stmt *BaseStmt) error(op string, err error) error {
+	return wrapError(err, op, stmt.Query, "", uuid.Nil)
+}
+
+// Exec executes a gosql statement with the given arguments and returns its result, e.g. the number of affected rows
+func (stmt *ExecStmt) Exec(ctx context.Context, tx *sql.Tx, args ...any) (sql.Result, error) {
 	slog.DebugContext(ctx, "Executing gosql statement", "stmt", stmt.Query, "cache", stmt.Cache)
-	stmtToUse, err := stmt.prepare(ctx, tx)
+	stmtToUse, args, cached, err := stmt.prepare(ctx, tx, args)
 	if err != nil {
-		return err
+		return nil, stmt.error("ExecStmt.Exec", err)
 	}
 
-	if !stmt.Cache {
+	if !cached {
 		defer stmtToUse.Close()
 	}
 
-	return Exec(ctx, tx, stmtToUse, args...)
+	res, err := Exec(ctx, tx, stmtToUse, args...)
+	return res, stmt.error("ExecStmt.Exec", err)
 }
 
 // Close releases resources associated with the statement
+// The cached statements of the query are removed from the statement cache for the databases the statement was executed on,
+// statements of the same query cached for other databases are kept
 func (stmt *BaseStmt) Close(ctx context.Context) error {
+	return stmt.close(ctx, nil)
+}
+
+// close removes the cached statements of the query for the database,
+// or for all databases the statement was cached for if db is nil
+func (stmt *BaseStmt) close(ctx context.Context, db *sql.DB) error {
 	slog.DebugContext(ctx, "Closing cached statement", "stmt", stmt.Query)
-	if stmt.cachedStmt != nil {
-		err := stmt.cachedStmt.Close()
-		if err != nil {
-			slog.ErrorContext(ctx, "Failed to close cached statement", "error", err)
-			return err
+	if !stmt.Cache {
+		return nil
+	}
+	query := parseNamedQuery(stmt.Query).rebind(stmt.getDialect())
+	keys := []cachedInKey{{cache: stmt.getStmtCache(), db: db}}
+	if db == nil {
+		keys = stmt.getCachedIn()
+	}
+	errs := make([]error, 0)
+	for _, key := range keys {
+		if err := key.cache.removeQuery(ctx, key.db, query); err != nil {
+			errs = append(errs, err)
 		}
 	}
+	if err := errors.Join(errs...); err != nil {
+		slog.ErrorContext(ctx, "Failed to close cached statement", "error", err)
+		return stmt.error("Stmt.Close", err)
+	}
 	return nil
 }
 
 // Query executes a SQL query and returns a single scalar value
 func (stmt *QueryValStmt[T]) Query(ctx context.Context, tx *sql.Tx, args ...any) (T, error) {
 	slog.DebugContext(ctx, "Executing gosql query for scalar value", "stmt", stmt.Query, "args_count", len(args))
-	stmtToUse, err := stmt.prepare(ctx, tx)
+	stmtToUse, args, cached, err := stmt.prepare(ctx, tx, args)
 	if err != nil {
-		return Nil[T](), err
+		return Nil[T](), stmt.error("QueryValStmt.Query", err)
 	}
 
-	if !stmt.Cache {
+	if !cached {
 		defer stmtToUse.Close()
 	}
 
-	return QueryVal[T](ctx, tx, stmtToUse, args...)
+	res, err := QueryVal[T](ctx, tx, stmtToUse, args...)
+	return res, stmt.error("QueryValStmt.Query", err)
 }
 
 // Query executes a SQL query and returns multiple entities
 func (stmt *QueryStmt[T]) Query(ctx context.Context, tx *sql.Tx, args ...any) ([]T, error) {
 	slog.DebugContext(ctx, "Executing gosql query", "stmt", stmt.Query, "args_count", len(args))
-	stmtToUse, err := stmt.prepare(ctx, tx)
+	stmtToUse, args, cached, err := stmt.prepare(ctx, tx, args)
 	if err != nil {
-		return nil, err
+		return nil, stmt.error("QueryStmt.Query", err)
 	}
 
-	if !stmt.Cache {
+	if !cached {
 		defer stmtToUse.Close()
 	}
 
-	return Query(ctx, tx, stmtToUse, stmt.NewReceiver, stmt.Receive, args...)
+	res, err := Query(ctx, tx, stmtToUse, stmt.NewReceiver, stmt.Receive, args...)
+	return res, stmt.error("QueryStmt.Query", err)
+}
+
+// Stream executes a SQL query and returns a sequence of entities that are scanned lazily while the caller ranges over it
+// The statement and rows stay open until the iteration ends
+func (stmt *QueryStmt[T]) Stream(ctx context.Context, tx *sql.Tx, args ...any) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		slog.DebugContext(ctx, "Executing gosql query for streaming", "stmt", stmt.Query, "args_count", len(args))
+		stmtToUse, args, cached, err := stmt.prepare(ctx, tx, args)
+		if err != nil {
+			yield(Nil[T](), stmt.error("QueryStmt.Stream", err))
+			return
+		}
+
+		if !cached {
+			defer stmtToUse.Close()
+		}
+
+		Stream(ctx, tx, stmtToUse, stmt.NewReceiver, stmt.Receive, args...)(func(t T, err error) bool {
+			return yield(t, stmt.error("QueryStmt.Stream", err))
+		})
+	}
 }
 
 // Query executes a SQL query and returns a single entity
 func (stmt *QueryOneStmt[T]) Query(ctx context.Context, tx *sql.Tx, args ...any) (T, error) {
 	slog.DebugContext(ctx, "Executing gosql query", "stmt", stmt.Query, "args_count", len(args))
-	stmtToUse, err := stmt.prepare(ctx, tx)
+	stmtToUse, args, cached, err := stmt.prepare(ctx, tx, args)
 	if err != nil {
-		return Nil[T](), err
+		return Nil[T](), stmt.error("QueryOneStmt.Query", err)
 	}
 
-	if !stmt.Cache {
+	if !cached {
 		defer stmtToUse.Close()
 	}
 
-	return QueryOne(ctx, tx, stmtToUse, stmt.NewReceiver, stmt.Receive, args...)
+	res, err := QueryOne(ctx, tx, stmtToUse, stmt.NewReceiver, stmt.Receive, args...)
+	return res, stmt.error("QueryOneStmt.Query", err)
 }
 
 // QueryPage executes a gosql query with pagination and returns a Page of results
+// The paging is validated with DefaultPagingPolicy
 func (stmt *QueryPageStmt[T]) QueryPage(ctx context.Context, tx *sql.Tx, paging Paging, args ...any) (Page[T], error) {
+	return stmt.queryPage(ctx, tx, paging, DefaultPagingPolicy, args...)
+}
+
+// queryPage executes the query with the paging policy
+// Failures to prepare the count statement are reported with its query, any other failures with the query of the page
+func (stmt *QueryPageStmt[T]) queryPage(ctx context.Context, tx *sql.Tx, paging Paging, policy *PagingPolicy, args ...any) (Page[T], error) {
 	slog.DebugContext(ctx, "Executing gosql query with pagination", "stmt", stmt.QueryStmt.Query, "args_count", len(args), "paging", paging)
-	countStmt, err := stmt.CountStmt.prepare(ctx, tx)
-	if err != nil {
-		return Page[T]{}, err
+	var countStmt *sql.Stmt
+	var countArgs []any
+	if !paging.SkipCount {
+		var countCached bool
+		var err error
+		countStmt, countArgs, countCached, err = stmt.CountStmt.prepare(ctx, tx, args)
+		if err != nil {
+			return Page[T]{}, stmt.CountStmt.error("QueryPageStmt.QueryPage", err)
+		}
+		if !countCached {
+			defer countStmt.Close()
+		}
 	}
-	queryStmt, err := stmt.QueryStmt.prepare(ctx, tx)
+	// the query is bound once the page's limit and offset are known, as they follow the arguments,
+	// but being scalars they don't affect the query text
+	named := parseNamedQuery(stmt.QueryStmt.BaseStmt.Query)
+	dialect := stmt.QueryStmt.getDialect()
+	query, _, expanded, err := named.prepareArgs(append(slices.Clone(args), dialect.LimitOffsetArgs(0, 0)...), dialect)
 	if err != nil {
-		return Page[T]{}, err
+		slog.ErrorContext(ctx, "Failed to bind named arguments", "query", stmt.QueryStmt.BaseStmt.Query, "error", err)
+		return Page[T]{}, stmt.QueryStmt.error("QueryPageStmt.QueryPage", err)
 	}
-
-	if !stmt.CountStmt.Cache {
-		defer countStmt.Close()
+	queryStmt, queryCached, err := stmt.QueryStmt.prepareQuery(ctx, tx, query, !expanded)
+	if err != nil {
+		return Page[T]{}, stmt.QueryStmt.error("QueryPageStmt.QueryPage", err)
 	}
-	if !stmt.QueryStmt.Cache {
+	if !queryCached {
 		defer queryStmt.Close()
 	}
+	queryArgs := func(limit, offset int) ([]any, error) {
+		_, args, _, err := named.prepareArgs(append(slices.Clone(args), dialect.LimitOffsetArgs(limit, offset)...), dialect)
+		return args, err
+	}
 
-	return QueryPage[T](ctx, tx, countStmt, queryStmt, paging, stmt.QueryStmt.NewReceiver, stmt.QueryStmt.Receive, args...)
+	res, err := queryPage[T](ctx, tx, countStmt, queryStmt, paging, policy, stmt.QueryStmt.NewReceiver, stmt.QueryStmt.Receive, countArgs, queryArgs)
+	return res, stmt.QueryStmt.error("QueryPageStmt.QueryPage", err)
 }
 
 // Close releases resources associated with the paginated query statement
 func (stmt *QueryPageStmt[T]) Close(ctx context.Context) error {
+	return stmt.close(ctx, nil)
+}
+
+func (stmt *QueryPageStmt[T]) close(ctx context.Context, db *sql.DB) error {
 	slog.DebugContext(ctx, "Closing paginated query statement")
 	errs := make([]error, 0, 2)
-	if err := stmt.CountStmt.Close(ctx); err != nil {
+	if err := stmt.CountStmt.close(ctx, db); err != nil {
 		slog.ErrorContext(ctx, "Failed to close count statement", "error", err)
 		errs = append(errs, err)
 	}
-	if err := stmt.QueryStmt.Close(ctx); err != nil {
+	if err := stmt.QueryStmt.close(ctx, db); err != nil {
 		slog.ErrorContext(ctx, "Failed to close query statement", "error", err)
 		errs = append(errs, err)
 	}
 	if len(errs) > 0 {
-		return errors.Join(errs...)
+		return wrapError(errors.Join(errs...), "QueryPageStmt.Close", "", "", uuid.Nil)
 	}
 	return nil
 }
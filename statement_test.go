@@ -0,0 +1,91 @@
+package gosql
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestCachedStmtReuseAcrossTransactions(t *testing.T) {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "test.db")+"?_journal_mode=WAL&_busy_timeout=1000")
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	// Create test table
+	_, err = db.Exec(`CREATE TABLE test (id INTEGER PRIMARY KEY, value TEXT)`)
+	if err != nil {
+		t.Fatalf("Failed to create table: %v", err)
+	}
+
+	cache := NewStmtCache(10)
+	insertStmt := &ExecStmt{BaseStmt: BaseStmt{Query: "INSERT INTO test (value) VALUES (?)", Cache: true, stmtCache: cache}}
+	countStmt := &QueryValStmt[int]{BaseStmt: BaseStmt{Query: "SELECT COUNT(*) FROM test", Cache: true, stmtCache: cache}}
+	defer cache.Close(ctx)
+
+	insert := func(value string) error {
+		return ExecWithTx(ctx, db, RW, func(ctx context.Context, tx *sql.Tx) error {
+			_, err := insertStmt.Exec(ctx, tx, value)
+			return err
+		})
+	}
+
+	if err := insert("first"); err != nil {
+		t.Fatalf("Failed to insert first row: %v", err)
+	}
+	if stats := cache.Stats(); stats.Misses != 1 || stats.Size != 1 {
+		t.Fatalf("Expected statement to be cached, got %+v", stats)
+	}
+
+	// The cached statement survives the commit of the transaction that prepared it
+	if err := insert("second"); err != nil {
+		t.Fatalf("Failed to insert second row after commit: %v", err)
+	}
+	if stats := cache.Stats(); stats.Hits != 1 || stats.Misses != 1 {
+		t.Errorf("Expected cached statement to be reused after commit, got %+v", stats)
+	}
+
+	// Concurrent transactions share the cached statement
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			count, err := QueryWithTx(ctx, db, RO, func(ctx context.Context, tx *sql.Tx) (int, error) {
+				return countStmt.Query(ctx, tx)
+			})
+			if err == nil && count != 2 {
+				t.Errorf("Expected 2 rows, got %d", count)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Failed to count rows concurrently: %v", err)
+		}
+	}
+	if stats := cache.Stats(); stats.Size != 2 || stats.Hits+stats.Misses != 12 {
+		t.Errorf("Expected 2 cached statements used 12 times, got %+v", stats)
+	}
+
+	// Statements used with transactions not started by gosql are not cached
+	adHocStmt := &QueryValStmt[int]{BaseStmt: BaseStmt{Query: "SELECT id FROM test WHERE id = 1", Cache: true, stmtCache: cache}}
+	tx, err := db.BeginTx(ctx, RO)
+	if err != nil {
+		t.Fatalf("Failed to begin transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if _, err := adHocStmt.Query(ctx, tx); err != nil {
+		t.Fatalf("Failed to query value: %v", err)
+	}
+	if stats := cache.Stats(); stats.Size != 2 {
+		t.Errorf("Expected statement not to be cached for transaction not started by gosql, got %+v", stats)
+	}
+}
@@ -0,0 +1,150 @@
+package gosql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+)
+
+// Table describes the table of an entity, from which DaoBuilder generates the statements that aren't configured
+type Table struct {
+	// Name is the name of the table
+	Name string
+	// IDColumn is the primary key column, "id" if empty
+	IDColumn string
+	// VersionColumn is the version column, "version" if empty
+	VersionColumn string
+	// Columns lists all columns of the table including the ID and version columns.
+	// The columns are derived from the db tags of the entity by Mapper if empty
+	Columns []string
+	// OrderBy lists the columns that order the pages of ListAllPageStmt, the ID column if empty
+	OrderBy []string
+}
+
+func (t *Table) idColumn() string {
+	if t.IDColumn == "" {
+		return "id"
+	}
+	return t.IDColumn
+}
+
+func (t *Table) versionColumn() string {
+	if t.VersionColumn == "" {
+		return "version"
+	}
+	return t.VersionColumn
+}
+
+// withTable returns the builder with the statements and functions that are nil generated from the table
+// Functions are derived by Mapper, binding the columns in the order of the generated statements
+// Along with a generated UpdateStmt and DeleteByIdStmt, UpdateByIdAndVersionStmt and DeleteByIdAndVersionStmt
+// are generated to compare the version unless configured
+func (b DaoBuilder[T]) withTable(ctx context.Context) (DaoBuilder[T], error) {
+	if b.Table == nil {
+		return b, nil
+	}
+	table := b.Table
+	if table.Name == "" {
+		slog.ErrorContext(ctx, "table.Name is empty")
+		return b, errors.New("gosql: table.Name is empty")
+	}
+
+	var mapper *Mapper[T]
+	columns := table.Columns
+	needsMapper := len(columns) == 0 || b.NewReceiver == nil || b.Receive == nil || b.InsertArgs == nil || b.UpdateArgs == nil
+	if needsMapper {
+		var err error
+		if mapper, err = NewMapper[T](); err != nil {
+			slog.ErrorContext(ctx, "Failed to map entity of table", "table", table.Name, "error", err)
+			return b, err
+		}
+	}
+	if len(columns) == 0 {
+		columns = mapper.Columns()
+	}
+
+	id, version := table.idColumn(), table.versionColumn()
+	if !slices.Contains(columns, id) || !slices.Contains(columns, version) {
+		slog.ErrorContext(ctx, "table.Columns must contain id and version columns", "table", table.Name, "id", id, "version", version)
+		return b, fmt.Errorf("gosql: columns of table %s must contain %s and %s", table.Name, id, version)
+	}
+	orderBy := table.OrderBy
+	if len(orderBy) == 0 {
+		orderBy = []string{id}
+	}
+	updateColumns := append(nonKeyColumns(columns, []string{id}), id)
+
+	dialect := b.Dialect
+	if dialect == nil {
+		dialect = DefaultDialect
+	}
+	name := dialect.Quote(table.Name)
+	selectAll := "SELECT " + quoteAll(dialect, columns) + " FROM " + name
+	whereID := " WHERE " + dialect.Quote(id) + " = ?"
+	whereIDs := " WHERE " + dialect.Quote(id) + " IN (?)"
+
+	if b.InsertStmt == nil {
+		b.InsertStmt = &DaoExecStmt{Query: insertInto(dialect, table.Name, columns), Cache: true}
+	}
+	if b.UpdateStmt == nil {
+		sets := make([]string, 0, len(updateColumns)-1)
+		for _, column := range updateColumns[:len(updateColumns)-1] {
+			sets = append(sets, dialect.Quote(column)+" = ?")
+		}
+		query := "UPDATE " + name + " SET " + strings.Join(sets, ", ") + whereID
+		b.UpdateStmt = &DaoExecStmt{Query: query, Cache: true}
+		if b.UpdateByIdAndVersionStmt == nil {
+			b.UpdateByIdAndVersionStmt = &DaoExecStmt{Query: query + " AND " + dialect.Quote(version) + " = ?", Cache: true}
+		}
+	}
+	if b.GetByIdStmt == nil {
+		b.GetByIdStmt = &DaoQueryOneStmt[T]{Query: selectAll + whereID, Cache: true}
+	}
+	if b.ListAllStmt == nil {
+		b.ListAllStmt = &DaoQueryStmt[T]{Query: selectAll, Cache: true}
+	}
+	if b.ListAllPageStmt == nil {
+		b.ListAllPageStmt = &DaoQueryPageStmt[T]{
+			CountStmt: &DaoQueryValStmt[int]{Query: "SELECT COUNT(*) FROM " + name, Cache: true},
+			QueryStmt: &DaoQueryStmt[T]{Query: selectAll + " ORDER BY " + quoteAll(dialect, orderBy) + " " + dialect.LimitOffset(), Cache: true},
+		}
+	}
+	if b.DeleteByIdStmt == nil {
+		query := "DELETE FROM " + name + whereID
+		b.DeleteByIdStmt = &DaoExecStmt{Query: query, Cache: true}
+		if b.DeleteByIdAndVersionStmt == nil {
+			b.DeleteByIdAndVersionStmt = &DaoExecStmt{Query: query + " AND " + dialect.Quote(version) + " = ?", Cache: true}
+		}
+	}
+	// statements with slice arguments are expanded on every call, so they aren't cached
+	if b.FindByIdsStmt == nil {
+		b.FindByIdsStmt = &DaoQueryStmt[T]{Query: selectAll + whereIDs}
+	}
+	if b.DeleteByIdsStmt == nil {
+		b.DeleteByIdsStmt = &DaoExecStmt{Query: "DELETE FROM " + name + whereIDs}
+	}
+
+	var err error
+	if b.NewReceiver == nil {
+		b.NewReceiver = mapper.NewReceiver
+	}
+	if b.Receive == nil {
+		if b.Receive, err = mapper.ReceiveFunc(columns...); err != nil {
+			return b, err
+		}
+	}
+	if b.InsertArgs == nil {
+		if b.InsertArgs, err = mapper.ArgsFunc(columns...); err != nil {
+			return b, err
+		}
+	}
+	if b.UpdateArgs == nil {
+		if b.UpdateArgs, err = mapper.ArgsFunc(updateColumns...); err != nil {
+			return b, err
+		}
+	}
+	return b, nil
+}
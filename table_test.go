@@ -0,0 +1,164 @@
+package gosql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func newTableDaoBuilder[T Entity](db *sql.DB, table *Table) DaoBuilder[T] {
+	return DaoBuilder[T]{
+		DB:             db,
+		Table:          table,
+		SaveChildren:   func(ctx context.Context, tx *sql.Tx, e T) error { return nil },
+		LoadChildren:   func(ctx context.Context, tx *sql.Tx, e T) error { return nil },
+		DeleteChildren: func(ctx context.Context, tx *sql.Tx, e T) error { return nil },
+	}
+}
+
+func TestTableDao(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	departmentDao, err := newTableDaoBuilder[*Department](db, &Table{Name: "departments", OrderBy: []string{"name"}}).Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create department DAO: %v", err)
+	}
+	defer departmentDao.Close(ctx)
+	studentDao, err := newTableDaoBuilder[*Student](db, &Table{Name: "students"}).Build(ctx)
+	if err != nil {
+		t.Fatalf("Failed to create student DAO: %v", err)
+	}
+	defer studentDao.Close(ctx)
+
+	math := &Department{Name: "Math"}
+	physics := &Department{Name: "Physics"}
+	if err := departmentDao.Save(ctx, physics, math); err != nil {
+		t.Fatalf("Failed to save departments: %v", err)
+	}
+	student := &Student{Name: "John", Department: math}
+	if err := studentDao.Save(ctx, student); err != nil {
+		t.Fatalf("Failed to save student: %v", err)
+	}
+
+	student.Department = physics
+	if err := studentDao.Save(ctx, student); err != nil {
+		t.Fatalf("Failed to update student: %v", err)
+	}
+	found, err := studentDao.FindById(ctx, student.ID)
+	if err != nil {
+		t.Fatalf("Failed to find student: %v", err)
+	}
+	if found.Name != "John" || found.Version != student.Version || found.Department == nil || found.Department.ID != physics.ID {
+		t.Errorf("Unexpected student %+v", found)
+	}
+
+	page, err := departmentDao.ListPage(ctx, Paging{PageNum: 1, PageSize: 1})
+	if err != nil {
+		t.Fatalf("Failed to list departments page: %v", err)
+	}
+	if page.TotalItems != 2 || len(page.Items) != 1 || page.Items[0].Name != "Math" {
+		t.Errorf("Expected the first page ordered by name, got %+v", page)
+	}
+
+	departments, err := departmentDao.FindByIds(ctx, physics.ID, math.ID, uuid.New())
+	if err != nil {
+		t.Fatalf("Failed to find departments by IDs: %v", err)
+	}
+	if len(departments) != 2 || departments[0].ID != physics.ID || departments[1].ID != math.ID {
+		t.Errorf("Unexpected departments %v", departments)
+	}
+
+	if err := studentDao.DeleteByIds(ctx, student.ID); err != nil {
+		t.Fatalf("Failed to delete student: %v", err)
+	}
+	if err := departmentDao.Delete(ctx, math); err != nil {
+		t.Fatalf("Failed to delete department: %v", err)
+	}
+	if count, err := departmentDao.CountBy(ctx, nil); err != nil || count != 1 {
+		t.Errorf("Expected 1 department left, got %d, %v", count, err)
+	}
+}
+
+func TestTableStatements(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	builder := newTableDaoBuilder[*Student](db, &Table{
+		Name:          "school.students",
+		IDColumn:      "student_id",
+		VersionColumn: "revision",
+		Columns:       []string{"student_id", "name", "revision"},
+	})
+	builder.Dialect = Postgres
+	builder.NewReceiver = func() *Student { return &Student{} }
+	builder.Receive = func(s *Student) []any { return []any{&s.ID, &s.Name, &s.Version} }
+	builder.InsertArgs = func(s *Student) []any { return []any{s.ID, s.Name, s.Version} }
+	builder.UpdateArgs = func(s *Student) []any { return []any{s.Name, s.Version, s.ID} }
+	builder.DeleteByIdStmt = &DaoExecStmt{Query: "UPDATE school.students SET deleted = TRUE WHERE student_id = ?"}
+	generated, err := builder.withTable(ctx)
+	if err != nil {
+		t.Fatalf("Failed to generate statements: %v", err)
+	}
+
+	expected := map[string]string{
+		"insert":      `INSERT INTO "school"."students" ("student_id", "name", "revision") VALUES (?, ?, ?)`,
+		"update":      `UPDATE "school"."students" SET "name" = ?, "revision" = ? WHERE "student_id" = ?`,
+		"updateCAS":   `UPDATE "school"."students" SET "name" = ?, "revision" = ? WHERE "student_id" = ? AND "revision" = ?`,
+		"getById":     `SELECT "student_id", "name", "revision" FROM "school"."students" WHERE "student_id" = ?`,
+		"listAll":     `SELECT "student_id", "name", "revision" FROM "school"."students"`,
+		"count":       `SELECT COUNT(*) FROM "school"."students"`,
+		"page":        `SELECT "student_id", "name", "revision" FROM "school"."students" ORDER BY "student_id" LIMIT ? OFFSET ?`,
+		"deleteById":  "UPDATE school.students SET deleted = TRUE WHERE student_id = ?",
+		"findByIds":   `SELECT "student_id", "name", "revision" FROM "school"."students" WHERE "student_id" IN (?)`,
+		"deleteByIds": `DELETE FROM "school"."students" WHERE "student_id" IN (?)`,
+	}
+	actual := map[string]string{
+		"insert":      generated.InsertStmt.Query,
+		"update":      generated.UpdateStmt.Query,
+		"updateCAS":   generated.UpdateByIdAndVersionStmt.Query,
+		"getById":     generated.GetByIdStmt.Query,
+		"listAll":     generated.ListAllStmt.Query,
+		"count":       generated.ListAllPageStmt.CountStmt.Query,
+		"page":        generated.ListAllPageStmt.QueryStmt.Query,
+		"deleteById":  generated.DeleteByIdStmt.Query,
+		"findByIds":   generated.FindByIdsStmt.Query,
+		"deleteByIds": generated.DeleteByIdsStmt.Query,
+	}
+	for name, query := range expected {
+		if actual[name] != query {
+			t.Errorf("Expected %s statement %q, got %q", name, query, actual[name])
+		}
+	}
+
+	// The versioned delete is generated along with DeleteByIdStmt only
+	if generated.DeleteByIdAndVersionStmt != nil {
+		t.Errorf("Expected no versioned delete for a configured DeleteByIdStmt, got %q", generated.DeleteByIdAndVersionStmt.Query)
+	}
+	builder.DeleteByIdStmt = nil
+	if generated, err = builder.withTable(ctx); err != nil {
+		t.Fatalf("Failed to generate statements: %v", err)
+	}
+	expectedDelete := `DELETE FROM "school"."students" WHERE "student_id" = ? AND "revision" = ?`
+	if generated.DeleteByIdAndVersionStmt == nil || generated.DeleteByIdAndVersionStmt.Query != expectedDelete {
+		t.Errorf("Expected versioned delete statement %q, got %+v", expectedDelete, generated.DeleteByIdAndVersionStmt)
+	}
+}
+
+func TestTableInvalid(t *testing.T) {
+	db := initDB(t)
+	defer db.Close()
+
+	if _, err := newTableDaoBuilder[*Department](db, &Table{}).Build(ctx); err == nil {
+		t.Errorf("Expected error for empty table name")
+	}
+	if _, err := newTableDaoBuilder[*Department](db, &Table{Name: "departments", IDColumn: "department_id"}).Build(ctx); err == nil {
+		t.Errorf("Expected error for missing ID column")
+	}
+	if _, err := newTableDaoBuilder[*Department](db, &Table{Name: "departments", Columns: []string{"id", "version", "code"}}).Build(ctx); !errors.Is(err, ErrInvalidMapping) {
+		t.Errorf("Expected ErrInvalidMapping for unmapped column, got %v", err)
+	}
+}